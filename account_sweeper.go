@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/service"
+)
+
+const accountSweeperWorker = "account_sweeper"
+
+// runAccountSweeper hard-deletes accounts whose grace period has run out.
+// Chirps, refresh tokens and everything else owned by the user go with them
+// through the ON DELETE CASCADE foreign keys.
+func (cfg *apiConfig) runAccountSweeper(ctx context.Context, interval time.Duration) {
+	cfg.workers.register(accountSweeperWorker, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.sweepDeletedAccounts(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) sweepDeletedAccounts(ctx context.Context) {
+	deleted, err := cfg.databaseQueries.HardDeleteExpiredUsers(ctx, service.AccountDeletionGraceDays)
+	cfg.workers.record(accountSweeperWorker, err)
+	if err != nil {
+		slog.ErrorContext(ctx, "Couldn't sweep deleted accounts", "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "Permanently deleted accounts past their grace period", "count", deleted)
+	}
+}
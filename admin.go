@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/config"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
+)
+
+// adminCommand is one operation under "chirpy admin". Destructive commands
+// get a -dry-run flag that looks up the target and reports what would
+// change without changing it.
+type adminCommand struct {
+	name        string
+	usage       string
+	destructive bool
+	run         func(ctx context.Context, a *adminCLI, fs *flag.FlagSet, args []string) error
+}
+
+var adminCommands = []adminCommand{
+	{name: "create-user", usage: "-email EMAIL [-password PASSWORD]", run: adminCreateUser},
+	{name: "reset-password", usage: "-user EMAIL|ID [-password PASSWORD]", destructive: true, run: adminResetPassword},
+	{name: "grant-red", usage: "-user EMAIL|ID", run: adminGrantRed},
+	{name: "revoke-tokens", usage: "-user EMAIL|ID", destructive: true, run: adminRevokeTokens},
+	{name: "delete-chirp", usage: "-id CHIRP_ID", destructive: true, run: adminDeleteChirp},
+	{name: "hide-chirp", usage: "-id CHIRP_ID", destructive: true, run: adminHideChirp},
+	{name: "recent-signups", usage: "[-since 24h] [-limit 20]", run: adminRecentSignups},
+}
+
+// adminCLI holds what every admin command shares: the queries and
+// services, who is running the command, where to read a password from when
+// it isn't passed as a flag, and how to print results.
+type adminCLI struct {
+	queries  database.Querier
+	service  *service.Service
+	mailer   mailer
+	operator string
+	stdin    io.Reader
+	stdout   io.Writer
+	output   string
+	dryRun   bool
+}
+
+// adminUser is how users are printed; it leaves out the password hash.
+type adminUser struct {
+	ID            uuid.UUID  `json:"id"`
+	Email         string     `json:"email"`
+	CreatedAt     time.Time  `json:"created_at"`
+	IsChirpyRed   bool       `json:"is_chirpy_red"`
+	EmailVerified bool       `json:"email_verified"`
+	SuspendedAt   *time.Time `json:"suspended_at,omitempty"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+}
+
+// adminAction reports the outcome of a command that changes something.
+type adminAction struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+	DryRun bool   `json:"dry_run"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func adminUsage() error {
+	var b strings.Builder
+	b.WriteString("usage: chirpy admin <command> [-output table|json] [flags]\n\ncommands:")
+	for _, c := range adminCommands {
+		dryRun := ""
+		if c.destructive {
+			dryRun = " [-dry-run]"
+		}
+		fmt.Fprintf(&b, "\n  %s %s%s", c.name, c.usage, dryRun)
+	}
+	return errors.New(b.String())
+}
+
+// runAdmin runs "chirpy admin <command> [flags]" against store. Results go
+// to stdout, and flag errors, help and, on the dev platform, email to
+// stderr.
+func runAdmin(ctx context.Context, conf config.Config, store service.Store, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	if len(args) == 0 {
+		return adminUsage()
+	}
+	for _, c := range adminCommands {
+		if c.name != args[0] {
+			continue
+		}
+		a := &adminCLI{
+			queries:  store,
+			service:  service.New(store, serviceOptions(conf)),
+			mailer:   newMailer(conf, stderr),
+			operator: adminOperator(),
+			stdin:    stdin,
+			stdout:   stdout,
+		}
+		fs := flag.NewFlagSet("chirpy admin "+c.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.StringVar(&a.output, "output", "table", "output format: table or json")
+		if c.destructive {
+			fs.BoolVar(&a.dryRun, "dry-run", false, "report what would change without changing it")
+		}
+		err := c.run(ctx, a, fs, args[1:])
+		if errors.As(err, new(usageError)) {
+			return fmt.Errorf("%v\n%v", err, adminUsage())
+		}
+		return err
+	}
+	return adminUsage()
+}
+
+// adminOperator names whoever is running chirpy admin, for the moderation
+// audit trail, since they aren't signed in as a user.
+func adminOperator() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return "chirpy admin, run by " + name
+}
+
+// usageError is a command line a command couldn't parse, which runAdmin
+// answers with the usage text.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+func (e usageError) Unwrap() error { return e.err }
+
+// parse parses the command's flags and checks the output format.
+func (a *adminCLI) parse(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	if err != nil {
+		return usageError{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError{fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))}
+	}
+	if a.output != "table" && a.output != "json" {
+		return fmt.Errorf("unknown output format %q, want table or json", a.output)
+	}
+	return nil
+}
+
+// password returns the password flag's value, or reads the first line of
+// stdin when it wasn't set so it needn't show up in shell history.
+func (a *adminCLI) password(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	line, err := bufio.NewReader(a.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// findUser looks a user up by ID or, failing that, by email.
+func (a *adminCLI) findUser(ctx context.Context, emailOrID string) (database.User, error) {
+	if emailOrID == "" {
+		return database.User{}, errors.New("-user is required")
+	}
+	var user database.User
+	var err error
+	if id, parseErr := uuid.Parse(emailOrID); parseErr == nil {
+		user, err = a.queries.GetUserByID(ctx, id)
+	} else {
+		user, err = a.queries.FindUserByEmail(ctx, emailOrID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("no user %q", emailOrID)
+	}
+	return user, err
+}
+
+func (a *adminCLI) findChirp(ctx context.Context, id string) (database.Chirp, error) {
+	chirpID, err := uuid.Parse(id)
+	if err != nil {
+		return database.Chirp{}, fmt.Errorf("-id must be a chirp ID: %w", err)
+	}
+	chirp, err := a.queries.RetrieveSingleChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Chirp{}, fmt.Errorf("no chirp %q", id)
+	}
+	return chirp, err
+}
+
+func (a *adminCLI) printUsers(users []database.User) error {
+	out := make([]adminUser, 0, len(users))
+	for _, u := range users {
+		user := adminUser{
+			ID:            u.ID,
+			Email:         u.Email,
+			CreatedAt:     u.CreatedAt,
+			IsChirpyRed:   u.IsChirpyRed.Bool,
+			EmailVerified: u.EmailVerifiedAt.Valid,
+		}
+		if u.SuspendedAt.Valid {
+			user.SuspendedAt = &u.SuspendedAt.Time
+		}
+		if u.DeletedAt.Valid {
+			user.DeletedAt = &u.DeletedAt.Time
+		}
+		out = append(out, user)
+	}
+	if a.output == "json" {
+		return a.printJSON(out)
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tCREATED AT\tCHIRPY RED\tVERIFIED\tSTATUS")
+	for _, u := range out {
+		status := "active"
+		switch {
+		case u.DeletedAt != nil:
+			status = "deleted"
+		case u.SuspendedAt != nil:
+			status = "suspended"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%t\t%s\n", u.ID, u.Email, u.CreatedAt.UTC().Format(time.RFC3339), u.IsChirpyRed, u.EmailVerified, status)
+	}
+	return tw.Flush()
+}
+
+func (a *adminCLI) printAction(action adminAction) error {
+	action.DryRun = a.dryRun
+	if a.output == "json" {
+		return a.printJSON(action)
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tTARGET\tDRY RUN\tDETAIL")
+	fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", action.Action, action.Target, action.DryRun, action.Detail)
+	return tw.Flush()
+}
+
+func (a *adminCLI) printJSON(v any) error {
+	enc := json.NewEncoder(a.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func adminCreateUser(ctx context.Context, a *adminCLI, fs *flag.FlagSet, args []string) error {
+	email := fs.String("email", "", "email of the new user")
+	passwordFlag := fs.String("password", "", "password of the new user; read from stdin if unset")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	password, err := a.password(*passwordFlag)
+	if err != nil {
+		return err
+	}
+
+	v := validation.Validator{}
+	v.Required("email", *email)
+	v.Email("email", *email)
+	v.Required("password", password)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	signup, err := a.service.CreateUser(ctx, *email, password)
+	if errors.Is(err, service.ErrEmailTaken) {
+		return fmt.Errorf("a user with email %q already exists", *email)
+	}
+	if err != nil {
+		return err
+	}
+	err = sendEmailVerification(ctx, a.mailer, signup.User.Email, signup.VerificationToken)
+	if err != nil {
+		return fmt.Errorf("created %s but couldn't send the verification email: %w", signup.User.Email, err)
+	}
+	return a.printUsers([]database.User{signup.User})
+}
+
+// adminResetPassword sets a new password and, like a password change through
+// the API, signs the user out everywhere.
+func adminResetPassword(ctx context.Context, a *adminCLI, fs *flag.FlagSet, args []string) error {
+	emailOrID := fs.String("user", "", "email or ID of the user")
+	passwordFlag := fs.String("password", "", "new password; read from stdin if unset")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	user, err := a.findUser(ctx, *emailOrID)
+	if err != nil {
+		return err
+	}
+	action := adminAction{
+		Action: "reset-password",
+		Target: user.Email,
+		Detail: "refresh tokens revoked",
+	}
+	if a.dryRun {
+		return a.printAction(action)
+	}
+
+	password, err := a.password(*passwordFlag)
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		return errors.New("password is required")
+	}
+	err = a.service.ResetPassword(ctx, user.ID, password)
+	if err != nil {
+		return err
+	}
+	return a.printAction(action)
+}
+
+func adminGrantRed(ctx context.Context, a *adminCLI, fs *flag.FlagSet, args []string) error {
+	emailOrID := fs.String("user", "", "email or ID of the user")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	user, err := a.findUser(ctx, *emailOrID)
+	if err != nil {
+		return err
+	}
+	err = a.queries.UpgradeToChirpyRed(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	user.IsChirpyRed = sql.NullBool{Bool: true, Valid: true}
+	return a.printUsers([]database.User{user})
+}
+
+func adminRevokeTokens(ctx context.Context, a *adminCLI, fs *flag.FlagSet, args []string) error {
+	emailOrID := fs.String("user", "", "email or ID of the user")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	user, err := a.findUser(ctx, *emailOrID)
+	if err != nil {
+		return err
+	}
+	if !a.dryRun {
+		err = a.queries.RevokeAllRefreshTokensForUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return a.printAction(adminAction{
+		Action: "revoke-tokens",
+		Target: user.Email,
+	})
+}
+
+// adminDeleteChirp deletes a chirp and, like a moderator doing so through the
+// API, records it on the moderation audit trail.
+func adminDeleteChirp(ctx context.Context, a *adminCLI, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the chirp")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	chirp, err := a.findChirp(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if !a.dryRun {
+		err = a.service.ModerateChirp(ctx, chirp.ID, service.ActionDeleteChirp, a.operator)
+		if err != nil {
+			return err
+		}
+	}
+	return a.printAction(adminAction{
+		Action: "delete-chirp",
+		Target: chirp.ID.String(),
+		Detail: "by " + chirp.UserID.String(),
+	})
+}
+
+// adminHideChirp hides a chirp and, like a moderator doing so through the
+// API, records it on the moderation audit trail.
+func adminHideChirp(ctx context.Context, a *adminCLI, fs *flag.FlagSet, args []string) error {
+	id := fs.String("id", "", "ID of the chirp")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	chirp, err := a.findChirp(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if chirp.HiddenAt.Valid {
+		return fmt.Errorf("chirp %s is already hidden", chirp.ID)
+	}
+	if !a.dryRun {
+		err = a.service.ModerateChirp(ctx, chirp.ID, service.ActionHideChirp, a.operator)
+		if err != nil {
+			return err
+		}
+	}
+	return a.printAction(adminAction{
+		Action: "hide-chirp",
+		Target: chirp.ID.String(),
+		Detail: "by " + chirp.UserID.String(),
+	})
+}
+
+func adminRecentSignups(ctx context.Context, a *adminCLI, fs *flag.FlagSet, args []string) error {
+	since := fs.Duration("since", 24*time.Hour, "how far back to look")
+	limit := fs.Int("limit", defaultPageLimit, "maximum number of users to list")
+	if err := a.parse(fs, args); err != nil {
+		return err
+	}
+	if *since <= 0 || *limit <= 0 {
+		return errors.New("-since and -limit must be positive")
+	}
+	if *limit > maxPageLimit {
+		return fmt.Errorf("-limit must be at most %d", maxPageLimit)
+	}
+	users, err := a.queries.ListRecentUsers(ctx, database.ListRecentUsersParams{
+		Since:     time.Now().Add(-*since),
+		PageLimit: int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+	return a.printUsers(users)
+}
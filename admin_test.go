@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/config"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/memstore"
+	"github.com/dandytron/chirpy.git/internal/service"
+)
+
+// adminFixture is a store with one user, signed in, who has posted a chirp.
+type adminFixture struct {
+	t       *testing.T
+	store   *memstore.Store
+	conf    config.Config
+	session service.Session
+	chirpID string
+}
+
+func newAdminFixture(t *testing.T) *adminFixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &adminFixture{t: t, store: memstore.New(), conf: config.Default()}
+	svc := service.New(f.store, serviceOptions(f.conf))
+	if _, err := svc.CreateUser(ctx, "walt@example.com", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	session, err := svc.Login(ctx, "walt@example.com", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.session = session
+	chirp, err := svc.CreateChirp(ctx, session.User.ID, "say my name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.chirpID = chirp.ID.String()
+	return f
+}
+
+// run runs an admin command with "new password" waiting on stdin and
+// returns what it printed.
+func (f *adminFixture) run(args ...string) (string, error) {
+	f.t.Helper()
+	var stdout bytes.Buffer
+	err := runAdmin(context.Background(), f.conf, f.store, strings.NewReader("new password\n"), &stdout, io.Discard, args)
+	return stdout.String(), err
+}
+
+// unchanged fails the test if the user's password, session or chirp has
+// been touched.
+func (f *adminFixture) unchanged() {
+	f.t.Helper()
+	ctx := context.Background()
+	user, err := f.store.GetUserByID(ctx, f.session.User.ID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if auth.CheckPasswordHash(testPassword, user.HashedPassword) != nil {
+		f.t.Error("the password was changed")
+	}
+	if _, err := f.store.GetUserFromRefreshToken(ctx, f.session.RefreshToken); err != nil {
+		f.t.Errorf("the refresh token no longer works: %v", err)
+	}
+	chirps, err := f.store.ListChirpsByAuthor(ctx, user.ID)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if len(chirps) != 1 || chirps[0].HiddenAt.Valid {
+		f.t.Errorf("chirps = %+v, want the one chirp, visible", chirps)
+	}
+	if actions := f.moderationActions(); len(actions) != 0 {
+		f.t.Errorf("moderation actions = %+v, want none", actions)
+	}
+}
+
+func (f *adminFixture) moderationActions() []database.ModerationAction {
+	f.t.Helper()
+	actions, err := f.store.ListModerationActions(context.Background(), database.ListModerationActionsParams{Limit: 10})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return actions
+}
+
+func TestAdminDryRun(t *testing.T) {
+	f := newAdminFixture(t)
+	for _, args := range [][]string{
+		{"reset-password", "-user", "walt@example.com", "-dry-run"},
+		{"revoke-tokens", "-user", "walt@example.com", "-dry-run"},
+		{"delete-chirp", "-id", f.chirpID, "-dry-run"},
+		{"hide-chirp", "-id", f.chirpID, "-dry-run"},
+	} {
+		out, err := f.run(append(args, "-output", "json")...)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		var action adminAction
+		if err := json.Unmarshal([]byte(out), &action); err != nil {
+			t.Fatalf("%v printed invalid JSON %q: %v", args, out, err)
+		}
+		if action.Action != args[0] || !action.DryRun {
+			t.Errorf("%v printed %+v, want a dry run of %s", args, action, args[0])
+		}
+		f.unchanged()
+	}
+
+	// The same commands without -dry-run do change things.
+	if _, err := f.run("hide-chirp", "-id", f.chirpID); err != nil {
+		t.Fatal(err)
+	}
+	actions := f.moderationActions()
+	if len(actions) != 1 || actions[0].Action != service.ActionHideChirp || actions[0].TargetChirpID.UUID.String() != f.chirpID ||
+		!strings.HasPrefix(actions[0].Note, "chirpy admin") {
+		t.Errorf("moderation actions after hide-chirp = %+v, want it recorded", actions)
+	}
+	if _, err := f.run("delete-chirp", "-id", f.chirpID); err != nil {
+		t.Fatal(err)
+	}
+	if actions := f.moderationActions(); len(actions) != 2 || actions[0].Action != service.ActionDeleteChirp {
+		t.Errorf("moderation actions after delete-chirp = %+v, want it recorded", actions)
+	}
+	if _, err := f.run("reset-password", "-user", "walt@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := f.store.GetUserByID(context.Background(), f.session.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.CheckPasswordHash("new password", user.HashedPassword) != nil {
+		t.Error("reset-password didn't set the password read from stdin")
+	}
+}
+
+func TestAdminCreateUser(t *testing.T) {
+	ctx := context.Background()
+	f := newAdminFixture(t)
+	// The dev platform prints email, verification included, to stderr.
+	f.conf.Platform = "dev"
+	var mail bytes.Buffer
+	err := runAdmin(ctx, f.conf, f.store, strings.NewReader(testPassword+"\n"), io.Discard, &mail, []string{"create-user", "-email", "jesse@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(mail.String()), "\n")
+	if len(lines) < 2 || !strings.Contains(mail.String(), "mail to jesse@example.com") {
+		t.Fatalf("no verification email printed: %q", mail.String())
+	}
+	svc := service.New(f.store, serviceOptions(f.conf))
+	if err := svc.VerifyEmail(ctx, lines[len(lines)-2]); err != nil {
+		t.Fatalf("VerifyEmail() with the printed token: %v", err)
+	}
+	if _, err := svc.Login(ctx, "jesse@example.com", testPassword); err != nil {
+		t.Errorf("Login() as the new user: %v", err)
+	}
+
+	if _, err := f.run("create-user", "-email", "walt@example.com"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("create-user with a taken email error = %v", err)
+	}
+}
+
+func TestAdminFindUser(t *testing.T) {
+	f := newAdminFixture(t)
+	for _, user := range []string{"walt@example.com", f.session.User.ID.String()} {
+		out, err := f.run("grant-red", "-user", user, "-output", "json")
+		if err != nil {
+			t.Fatalf("grant-red -user %s: %v", user, err)
+		}
+		var users []adminUser
+		if err := json.Unmarshal([]byte(out), &users); err != nil {
+			t.Fatalf("grant-red printed invalid JSON %q: %v", out, err)
+		}
+		if len(users) != 1 || users[0].ID != f.session.User.ID || !users[0].IsChirpyRed {
+			t.Errorf("grant-red -user %s printed %+v", user, users)
+		}
+	}
+	if _, err := f.run("grant-red", "-user", "nobody@example.com"); err == nil || !strings.Contains(err.Error(), "no user") {
+		t.Errorf("grant-red for an unknown user error = %v", err)
+	}
+}
+
+func TestAdminUsage(t *testing.T) {
+	f := newAdminFixture(t)
+	for _, args := range [][]string{
+		nil,
+		{"launch-missiles"},
+		{"grant-red", "-bogus"},
+		{"grant-red", "-user", "walt@example.com", "extra"},
+		{"recent-signups", "-limit", "many"},
+	} {
+		_, err := f.run(args...)
+		if err == nil || !strings.Contains(err.Error(), "usage: chirpy admin") {
+			t.Errorf("%v error = %v, want the usage text", args, err)
+		}
+	}
+	// Only destructive commands take -dry-run.
+	if _, err := f.run("grant-red", "-user", "walt@example.com", "-dry-run"); err == nil {
+		t.Error("grant-red accepted -dry-run")
+	}
+	f.unchanged()
+}
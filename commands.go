@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dandytron/chirpy.git/internal/config"
+	"github.com/dandytron/chirpy.git/internal/migrations"
+	"github.com/dandytron/chirpy.git/internal/service"
+)
+
+// runCommand runs a subcommand such as "migrate up" or "admin grant-red"
+// instead of the server.
+func runCommand(ctx context.Context, conf config.Config, args []string) error {
+	if conf.DatabaseURL == "" {
+		return errors.New("db_url is required to run commands")
+	}
+	switch args[0] {
+	case "migrate":
+		if len(args) != 2 || !slices.Contains(migrations.Commands, args[1]) {
+			return fmt.Errorf("usage: chirpy migrate %s", strings.Join(migrations.Commands, "|"))
+		}
+		db, err := sql.Open("postgres", conf.DatabaseURL)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return migrations.Run(ctx, db, os.Stdout, args[1])
+	case "admin":
+		db, err := sql.Open("postgres", conf.DatabaseURL)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		store, err := cachedStore(service.Postgres(db), conf, true)
+		if err != nil {
+			return err
+		}
+		return runAdmin(ctx, conf, store, os.Stdin, os.Stdout, os.Stderr, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, want migrate or admin", args[0])
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+)
+
+// How long shared caches may serve chirps to anonymous readers without
+// checking back, and so how long a deleted chirp can linger there.
+const publicChirpMaxAge = 30 * time.Second
+
+// chirpETag is a strong validator for one chirp: it changes whenever the
+// chirp does, since every change bumps updated_at.
+func chirpETag(chirp database.Chirp) string {
+	h := sha256.New()
+	writeChirpVersion(h, chirp)
+	return etagFromHash(h)
+}
+
+// chirpsETag fingerprints a list of chirps, so it changes when any of them
+// changes or when one is added to or drops out of the list.
+func chirpsETag(chirps []database.Chirp) string {
+	h := sha256.New()
+	for _, chirp := range chirps {
+		writeChirpVersion(h, chirp)
+	}
+	return etagFromHash(h)
+}
+
+func writeChirpVersion(h hash.Hash, chirp database.Chirp) {
+	fmt.Fprintf(h, "%s %d\n", chirp.ID, chirp.UpdatedAt.UnixNano())
+}
+
+func etagFromHash(h hash.Hash) string {
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// setChirpCacheHeaders sets the validators and caching policy for a chirp
+// read. What a signed-in user sees depends on who they've blocked and muted,
+// so only anonymous responses may be shared between clients. lastModified
+// is left out when it's zero.
+func setChirpCacheHeaders(w http.ResponseWriter, viewerIsAnonymous bool, etag string, lastModified time.Time) {
+	h := w.Header()
+	h.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	// Added to, not set: the CORS middleware has already put Origin there.
+	// The viewer comes from the Authorization header or the access cookie.
+	h.Add("Vary", "Authorization")
+	h.Add("Vary", "Cookie")
+	if viewerIsAnonymous {
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(publicChirpMaxAge.Seconds())))
+	} else {
+		h.Set("Cache-Control", "private, no-cache")
+	}
+}
+
+// notModified reports whether the client's cached copy is still current, per
+// If-None-Match or, failing that, If-Modified-Since. The caller should then
+// answer with 304 and no body.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, etag, false)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// HTTP dates only go down to the second.
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// preconditionFailed reports whether an If-Match header rules out changing
+// a resource whose current ETag is etag.
+func preconditionFailed(r *http.Request, etag string) bool {
+	im := r.Header.Get("If-Match")
+	return im != "" && !etagListMatches(im, etag, true)
+}
+
+// etagListMatches reports whether etag is in a comma-separated If-Match or
+// If-None-Match list. Strong comparison never matches a weak tag.
+func etagListMatches(list, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak, ok := strings.CutPrefix(candidate, "W/"); ok {
+			if strong {
+				continue
+			}
+			candidate = weak
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1348 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/config"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/memstore"
+	"github.com/dandytron/chirpy.git/internal/metrics"
+	"github.com/dandytron/chirpy.git/internal/ratelimit"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/static"
+	"github.com/dandytron/chirpy.git/sql/schema"
+	"github.com/dandytron/chirpy.git/web"
+	"github.com/google/uuid"
+)
+
+// These tests run every route in routes.go against the in-memory store
+// through a real HTTP server, middleware included.
+
+const (
+	testPassword   = "correct horse battery staple"
+	testAdminEmail = "admin@example.com"
+	testPolkaKey   = "test-polka-key"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+type sentMail struct {
+	to, subject, body string
+}
+
+// recordingMailer keeps every message so tests can read verification tokens.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []sentMail
+}
+
+func (m *recordingMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentMail{to: to, subject: subject, body: body})
+	return nil
+}
+
+// lastToken returns the token at the end of the last message sent to to.
+func (m *recordingMailer) lastToken(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].to == to {
+			lines := strings.Split(strings.TrimSpace(m.sent[i].body), "\n")
+			return lines[len(lines)-1]
+		}
+	}
+	t.Fatalf("no mail sent to %s", to)
+	return ""
+}
+
+type testServer struct {
+	t    *testing.T
+	srv  *httptest.Server
+	cfg  *apiConfig
+	mail *recordingMailer
+}
+
+// newTestServer starts a server on a fresh store. Rate limits are off unless
+// configure turns them on, since most tests make far more auth requests than
+// one client is allowed.
+func newTestServer(t *testing.T, configure ...func(*config.Config)) *testServer {
+	t.Helper()
+	conf := config.Default()
+	conf.Store = "memory"
+	conf.Platform = "dev"
+	conf.JWTSecret = "test-secret"
+	conf.PolkaKey = testPolkaKey
+	conf.AdminEmail = testAdminEmail
+	conf.RateLimitAuth = ratelimit.Policy{}
+	conf.RateLimitWrites = ratelimit.Policy{}
+	conf.RateLimitReads = ratelimit.Policy{}
+	conf.RateLimitWebhooks = ratelimit.Policy{}
+	for _, fn := range configure {
+		fn(&conf)
+	}
+
+	mail := &recordingMailer{}
+	// Run everything through the cache, so a missed invalidation shows up
+	// as a stale read somewhere in the suite.
+	store, err := cachedStore(memstore.New(), conf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webClient, err := static.New(web.FS, "/app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &apiConfig{
+		metrics:         metrics.New(nil),
+		databaseQueries: store,
+		service:         service.New(store, serviceOptions(conf)),
+		rateLimiter:     ratelimit.NewMemoryStore(),
+		config:          conf,
+		mailer:          mail,
+		workers:         newWorkerHealth(),
+		webClient:       webClient,
+	}
+	srv := httptest.NewServer(cfg.routes())
+	t.Cleanup(srv.Close)
+	return &testServer{t: t, srv: srv, cfg: cfg, mail: mail}
+}
+
+type testResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (r testResponse) decode(t *testing.T, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.body, v); err != nil {
+		t.Fatalf("couldn't decode %s: %v", r.body, err)
+	}
+}
+
+// send makes a request and fails the test unless it gets wantStatus.
+func (ts *testServer) send(req *http.Request, wantStatus int) testResponse {
+	ts.t.Helper()
+	resp, err := ts.srv.Client().Do(req)
+	if err != nil {
+		ts.t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if resp.StatusCode != wantStatus {
+		ts.t.Fatalf("%s %s = %d, want %d: %s", req.Method, req.URL.Path, resp.StatusCode, wantStatus, body)
+	}
+	return testResponse{status: resp.StatusCode, header: resp.Header, body: body}
+}
+
+func (ts *testServer) newRequest(method, path, token string, body any) *http.Request {
+	ts.t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, ts.srv.URL+path, r)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// call sends a JSON body with an optional bearer token.
+func (ts *testServer) call(method, path, token string, body any, wantStatus int) testResponse {
+	ts.t.Helper()
+	return ts.send(ts.newRequest(method, path, token, body), wantStatus)
+}
+
+type testUser struct {
+	ID           uuid.UUID
+	Email        string
+	Token        string
+	RefreshToken string
+}
+
+func (ts *testServer) signup(email string) testUser {
+	ts.t.Helper()
+	ts.call("POST", "/api/users", "", map[string]string{"email": email, "password": testPassword}, http.StatusCreated)
+	return ts.login(email)
+}
+
+func (ts *testServer) login(email string) testUser {
+	ts.t.Helper()
+	var resp struct {
+		ID           uuid.UUID `json:"id"`
+		Email        string    `json:"email"`
+		Token        string    `json:"token"`
+		RefreshToken string    `json:"refresh_token"`
+	}
+	ts.call("POST", "/api/login", "", map[string]string{"email": email, "password": testPassword}, http.StatusOK).decode(ts.t, &resp)
+	return testUser{ID: resp.ID, Email: resp.Email, Token: resp.Token, RefreshToken: resp.RefreshToken}
+}
+
+// admin signs up the admin email, verifies it and logs in again so the
+// token carries the admin role.
+func (ts *testServer) admin() testUser {
+	ts.t.Helper()
+	ts.call("POST", "/api/users", "", map[string]string{"email": testAdminEmail, "password": testPassword}, http.StatusCreated)
+	ts.call("POST", "/api/users/verify", "", map[string]string{"token": ts.mail.lastToken(ts.t, testAdminEmail)}, http.StatusNoContent)
+	return ts.login(testAdminEmail)
+}
+
+// moderator signs up a user, has the admin make them a moderator and logs
+// them in again so their token carries the new permissions.
+func (ts *testServer) moderator(admin testUser, email string) testUser {
+	ts.t.Helper()
+	user := ts.signup(email)
+	ts.call("POST", "/admin/users/"+user.ID.String()+"/roles", admin.Token, map[string]string{"role": "moderator"}, http.StatusNoContent)
+	return ts.login(email)
+}
+
+func (ts *testServer) chirp(user testUser, body string) Chirp {
+	ts.t.Helper()
+	var chirp Chirp
+	ts.call("POST", "/api/chirps", user.Token, map[string]string{"body": body}, http.StatusCreated).decode(ts.t, &chirp)
+	return chirp
+}
+
+func TestHealthMetricsAndFileserver(t *testing.T) {
+	ts := newTestServer(t)
+
+	for _, path := range []string{"/admin/healthz", "/admin/livez"} {
+		ts.call("GET", path, "", nil, http.StatusOK)
+	}
+	var readiness struct {
+		Status string `json:"status"`
+	}
+	ts.call("GET", "/admin/readyz", "", nil, http.StatusOK).decode(t, &readiness)
+	if readiness.Status != healthStatusOK {
+		t.Errorf("readiness status = %q, want %q", readiness.Status, healthStatusOK)
+	}
+
+	resp := ts.call("GET", "/app/", "", nil, http.StatusOK)
+	if !strings.Contains(string(resp.body), "Welcome to Chirpy") {
+		t.Errorf("GET /app/ = %q, want the index page", resp.body)
+	}
+
+	resp = ts.call("GET", "/metrics", "", nil, http.StatusOK)
+	if !strings.Contains(string(resp.body), "chirpy_fileserver_hits_total 1") {
+		t.Errorf("GET /metrics doesn't count the /app/ hit:\n%s", resp.body)
+	}
+}
+
+func TestWebClient(t *testing.T) {
+	ts := newTestServer(t)
+
+	page := ts.call("GET", "/app/", "", nil, http.StatusOK)
+	if got := page.header.Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("index Cache-Control = %q, want no-cache", got)
+	}
+	script := regexp.MustCompile(`src="(/app/assets/app\.[0-9a-f]+\.js)"`).FindSubmatch(page.body)
+	if script == nil {
+		t.Fatalf("index doesn't link to a hashed app.js:\n%s", page.body)
+	}
+	asset := ts.call("GET", string(script[1]), "", nil, http.StatusOK)
+	if got := asset.header.Get("Cache-Control"); !strings.Contains(got, "immutable") {
+		t.Errorf("hashed asset Cache-Control = %q, want immutable", got)
+	}
+	if got := asset.header.Get("Content-Type"); !strings.Contains(got, "javascript") {
+		t.Errorf("app.js Content-Type = %q", got)
+	}
+	// The brotli copy from go generate is checked in and up to date.
+	req := ts.newRequest("GET", string(script[1]), "", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	if got := ts.send(req, http.StatusOK).header.Get("Content-Encoding"); got != "br" {
+		t.Errorf("app.js Content-Encoding = %q, want br", got)
+	}
+
+	// Client-side routes get the app; nothing outside the embedded client is
+	// reachable, and directories aren't listed.
+	for _, path := range []string{"/app/login", "/app/chirps/" + uuid.NewString(), "/app/assets/"} {
+		resp := ts.call("GET", path, "", nil, http.StatusOK)
+		if !bytes.Equal(resp.body, page.body) {
+			t.Errorf("GET %s isn't the index page:\n%s", path, resp.body)
+		}
+	}
+	for _, path := range []string{"/app/.env", "/app/connection_string.txt", "/app/main.go", "/app/../go.mod", "/app/assets/missing.js", "/app/embed.go"} {
+		ts.call("GET", path, "", nil, http.StatusNotFound)
+	}
+	ts.call("POST", "/app/", "", nil, http.StatusMethodNotAllowed)
+}
+
+func TestSignupLoginAndTokens(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.call("POST", "/api/users", "", map[string]string{"email": "not-an-email", "password": testPassword}, http.StatusUnprocessableEntity)
+	user := ts.signup("walt@example.com")
+	if user.Token == "" || user.RefreshToken == "" {
+		t.Fatalf("login returned %+v, want both tokens", user)
+	}
+	ts.call("POST", "/api/login", "", map[string]string{"email": user.Email, "password": "wrong"}, http.StatusUnauthorized)
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	ts.call("POST", "/api/refresh", user.RefreshToken, nil, http.StatusOK).decode(t, &refreshed)
+	if refreshed.Token == "" {
+		t.Error("refresh returned no access token")
+	}
+	ts.call("POST", "/api/revoke", user.RefreshToken, nil, http.StatusNoContent)
+	ts.call("POST", "/api/refresh", user.RefreshToken, nil, http.StatusUnauthorized)
+}
+
+func TestUpdateUserAndVerifyEmail(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signup("jesse@example.com")
+	ts.signup("skyler@example.com")
+
+	ts.call("POST", "/api/users/verify", "", map[string]string{"token": "bogus"}, http.StatusBadRequest)
+	ts.call("POST", "/api/users/verify", "", map[string]string{"token": ts.mail.lastToken(t, user.Email)}, http.StatusNoContent)
+
+	ts.call("PUT", "/api/users", user.Token, map[string]string{"email": "new@example.com", "current_password": "wrong"}, http.StatusUnauthorized)
+	ts.call("PUT", "/api/users", user.Token, map[string]string{"email": "skyler@example.com", "current_password": testPassword}, http.StatusConflict)
+
+	var updated struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	ts.call("PUT", "/api/users", user.Token, map[string]string{"email": "pinkman@example.com", "current_password": testPassword}, http.StatusOK).decode(t, &updated)
+	if updated.Email != "pinkman@example.com" || updated.EmailVerified {
+		t.Errorf("PUT /api/users = %+v, want the new, unverified email", updated)
+	}
+	ts.call("POST", "/api/users/verify", "", map[string]string{"token": ts.mail.lastToken(t, "pinkman@example.com")}, http.StatusNoContent)
+
+	var changed struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	ts.call("PATCH", "/api/users", user.Token, map[string]string{"password": "new password", "current_password": testPassword}, http.StatusOK).decode(t, &changed)
+	if changed.RefreshToken == "" {
+		t.Error("password change didn't issue a new refresh token")
+	}
+	ts.call("POST", "/api/refresh", user.RefreshToken, nil, http.StatusUnauthorized)
+	ts.call("POST", "/api/refresh", changed.RefreshToken, nil, http.StatusOK)
+}
+
+func TestChirps(t *testing.T) {
+	ts := newTestServer(t)
+	author := ts.signup("author@example.com")
+	other := ts.signup("other@example.com")
+
+	ts.call("POST", "/api/chirps", "", map[string]string{"body": "hi"}, http.StatusUnauthorized)
+	ts.call("POST", "/api/chirps", author.Token, map[string]string{"body": strings.Repeat("a", 141)}, http.StatusUnprocessableEntity)
+	chirp := ts.chirp(author, "what a kerfuffle")
+	if chirp.Body != "what a ****" {
+		t.Errorf("chirp body = %q, want profanity scrubbed", chirp.Body)
+	}
+
+	var chirps []Chirp
+	ts.call("GET", "/api/chirps", "", nil, http.StatusOK).decode(t, &chirps)
+	if len(chirps) != 1 || chirps[0].ID != chirp.ID {
+		t.Errorf("GET /api/chirps = %+v, want the new chirp", chirps)
+	}
+	ts.call("GET", "/api/chirps/"+chirp.ID.String(), "", nil, http.StatusOK)
+	ts.call("GET", "/api/chirps/"+uuid.NewString(), "", nil, http.StatusNotFound)
+
+	ts.call("DELETE", "/api/chirps/"+chirp.ID.String(), other.Token, nil, http.StatusForbidden)
+	ts.call("DELETE", "/api/chirps/"+chirp.ID.String(), author.Token, nil, http.StatusNoContent)
+	ts.call("GET", "/api/chirps/"+chirp.ID.String(), "", nil, http.StatusNotFound)
+}
+
+func TestBlocksAndMutes(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signup("user@example.com")
+	troll := ts.signup("troll@example.com")
+	bore := ts.signup("bore@example.com")
+	ts.chirp(troll, "trolling")
+	ts.chirp(bore, "boring")
+
+	ts.call("POST", "/api/users/"+user.ID.String()+"/block", user.Token, nil, http.StatusBadRequest)
+	ts.call("POST", "/api/users/"+uuid.NewString()+"/block", user.Token, nil, http.StatusNotFound)
+	ts.call("POST", "/api/users/"+troll.ID.String()+"/block", user.Token, nil, http.StatusNoContent)
+	ts.call("POST", "/api/users/"+bore.ID.String()+"/mute", user.Token, nil, http.StatusNoContent)
+
+	var relationships []Relationship
+	ts.call("GET", "/api/users/me/blocks", user.Token, nil, http.StatusOK).decode(t, &relationships)
+	if len(relationships) != 1 || relationships[0].UserID != troll.ID {
+		t.Errorf("blocks = %+v, want the troll", relationships)
+	}
+	ts.call("GET", "/api/users/me/mutes", user.Token, nil, http.StatusOK).decode(t, &relationships)
+	if len(relationships) != 1 || relationships[0].UserID != bore.ID {
+		t.Errorf("mutes = %+v, want the bore", relationships)
+	}
+
+	var chirps []Chirp
+	ts.call("GET", "/api/chirps", user.Token, nil, http.StatusOK).decode(t, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("GET /api/chirps = %+v, want blocked and muted users hidden", chirps)
+	}
+
+	ts.call("DELETE", "/api/users/"+troll.ID.String()+"/block", user.Token, nil, http.StatusNoContent)
+	ts.call("DELETE", "/api/users/"+troll.ID.String()+"/block", user.Token, nil, http.StatusNotFound)
+	ts.call("DELETE", "/api/users/"+bore.ID.String()+"/mute", user.Token, nil, http.StatusNoContent)
+	ts.call("GET", "/api/chirps", user.Token, nil, http.StatusOK).decode(t, &chirps)
+	if len(chirps) != 2 {
+		t.Errorf("GET /api/chirps returned %d chirps after unblocking, want 2", len(chirps))
+	}
+}
+
+func TestReportsAndModeration(t *testing.T) {
+	ts := newTestServer(t)
+	admin := ts.admin()
+	mod := ts.moderator(admin, "mod@example.com")
+	reporter := ts.signup("reporter@example.com")
+	spammer := ts.signup("spammer@example.com")
+	chirp := ts.chirp(spammer, "buy now")
+
+	ts.call("POST", "/api/chirps/"+chirp.ID.String()+"/report", reporter.Token, map[string]string{"reason": "boring"}, http.StatusUnprocessableEntity)
+	ts.call("POST", "/api/chirps/"+chirp.ID.String()+"/report", spammer.Token, map[string]string{"reason": "spam"}, http.StatusBadRequest)
+	var chirpReport Report
+	ts.call("POST", "/api/chirps/"+chirp.ID.String()+"/report", reporter.Token, map[string]string{"reason": "spam"}, http.StatusCreated).decode(t, &chirpReport)
+	var userReport Report
+	ts.call("POST", "/api/users/"+spammer.ID.String()+"/report", reporter.Token, map[string]string{"reason": "harassment"}, http.StatusCreated).decode(t, &userReport)
+
+	ts.call("GET", "/api/moderation/reports", reporter.Token, nil, http.StatusForbidden)
+	var reports []Report
+	ts.call("GET", "/api/moderation/reports?status=open", mod.Token, nil, http.StatusOK).decode(t, &reports)
+	if len(reports) != 2 {
+		t.Fatalf("open reports = %d, want 2", len(reports))
+	}
+
+	var assigned Report
+	ts.call("POST", "/api/moderation/reports/"+chirpReport.ID.String()+"/assign", mod.Token, map[string]string{}, http.StatusOK).decode(t, &assigned)
+	if assigned.AssigneeID == nil || *assigned.AssigneeID != mod.ID {
+		t.Errorf("assignee = %v, want the moderator", assigned.AssigneeID)
+	}
+	ts.call("POST", "/api/moderation/reports/"+chirpReport.ID.String()+"/assign", mod.Token, map[string]string{"assignee_id": reporter.ID.String()}, http.StatusBadRequest)
+
+	var closed Report
+	ts.call("POST", "/api/moderation/reports/"+chirpReport.ID.String()+"/actions", mod.Token, map[string]string{"action": "hide_chirp"}, http.StatusOK).decode(t, &closed)
+	if closed.Status != "resolved" {
+		t.Errorf("status = %q, want resolved", closed.Status)
+	}
+	ts.call("GET", "/api/chirps/"+chirp.ID.String(), "", nil, http.StatusNotFound)
+	ts.call("POST", "/api/moderation/reports/"+chirpReport.ID.String()+"/actions", mod.Token, map[string]string{"action": "dismiss"}, http.StatusConflict)
+
+	ts.call("POST", "/api/moderation/reports/"+userReport.ID.String()+"/actions", mod.Token, map[string]string{"action": "suspend_user"}, http.StatusOK)
+	ts.call("POST", "/api/login", "", map[string]string{"email": spammer.Email, "password": testPassword}, http.StatusForbidden)
+	ts.call("POST", "/api/refresh", spammer.RefreshToken, nil, http.StatusUnauthorized)
+	// The access token they already had can't post or message either.
+	ts.call("POST", "/api/chirps", spammer.Token, map[string]string{"body": "still here"}, http.StatusForbidden)
+	ts.call("POST", "/api/conversations", spammer.Token, map[string][]uuid.UUID{"member_ids": {reporter.ID}}, http.StatusForbidden)
+
+	var actions []ModerationAction
+	ts.call("GET", "/api/moderation/actions", mod.Token, nil, http.StatusOK).decode(t, &actions)
+	// The role grant, the assignment and the two report actions.
+	if len(actions) != 4 {
+		t.Errorf("moderation actions = %d, want 4", len(actions))
+	}
+}
+
+func TestModeratorCanDeleteAnyChirp(t *testing.T) {
+	ts := newTestServer(t)
+	admin := ts.admin()
+	mod := ts.moderator(admin, "mod@example.com")
+	author := ts.signup("author@example.com")
+	chirp := ts.chirp(author, "remove me")
+
+	ts.call("DELETE", "/api/chirps/"+chirp.ID.String(), mod.Token, nil, http.StatusNoContent)
+	var actions []ModerationAction
+	ts.call("GET", "/api/moderation/actions", mod.Token, nil, http.StatusOK).decode(t, &actions)
+	if len(actions) == 0 || actions[0].Action != moderationActionDeleteChirp {
+		t.Errorf("latest moderation action = %+v, want %s", actions, moderationActionDeleteChirp)
+	}
+}
+
+func TestConversations(t *testing.T) {
+	ts := newTestServer(t)
+	alice := ts.signup("alice@example.com")
+	bob := ts.signup("bob@example.com")
+	eve := ts.signup("eve@example.com")
+
+	ts.call("POST", "/api/conversations", alice.Token, map[string]any{"member_ids": []uuid.UUID{alice.ID}}, http.StatusBadRequest)
+	var conversation Conversation
+	ts.call("POST", "/api/conversations", alice.Token, map[string]any{"member_ids": []uuid.UUID{bob.ID}}, http.StatusCreated).decode(t, &conversation)
+	var again Conversation
+	ts.call("POST", "/api/conversations", alice.Token, map[string]any{"member_ids": []uuid.UUID{bob.ID}}, http.StatusOK).decode(t, &again)
+	if again.ID != conversation.ID {
+		t.Errorf("second direct conversation = %s, want the existing %s", again.ID, conversation.ID)
+	}
+
+	messagesPath := "/api/conversations/" + conversation.ID.String() + "/messages"
+	var message Message
+	ts.call("POST", messagesPath, alice.Token, map[string]string{"body": "hi bob"}, http.StatusCreated).decode(t, &message)
+	ts.call("POST", messagesPath, eve.Token, map[string]string{"body": "hi"}, http.StatusNotFound)
+	ts.call("GET", messagesPath, eve.Token, nil, http.StatusNotFound)
+
+	var conversations []Conversation
+	ts.call("GET", "/api/conversations", bob.Token, nil, http.StatusOK).decode(t, &conversations)
+	if len(conversations) != 1 || conversations[0].UnreadCount != 1 || conversations[0].LastMessage == nil {
+		t.Fatalf("bob's conversations = %+v, want one with an unread message", conversations)
+	}
+
+	var messages []Message
+	ts.call("GET", messagesPath, bob.Token, nil, http.StatusOK).decode(t, &messages)
+	if len(messages) != 1 || messages[0].Body != "hi bob" {
+		t.Errorf("messages = %+v, want alice's message", messages)
+	}
+	ts.call("GET", "/api/conversations", bob.Token, nil, http.StatusOK).decode(t, &conversations)
+	if conversations[0].UnreadCount != 0 {
+		t.Errorf("unread count after reading = %d, want 0", conversations[0].UnreadCount)
+	}
+
+	ts.call("DELETE", messagesPath+"/"+message.ID.String(), bob.Token, nil, http.StatusNoContent)
+	ts.call("DELETE", messagesPath+"/"+message.ID.String(), eve.Token, nil, http.StatusNotFound)
+	ts.call("GET", messagesPath, bob.Token, nil, http.StatusOK).decode(t, &messages)
+	if len(messages) != 0 {
+		t.Errorf("bob still sees %d messages after deleting", len(messages))
+	}
+	ts.call("GET", messagesPath, alice.Token, nil, http.StatusOK).decode(t, &messages)
+	if len(messages) != 1 {
+		t.Errorf("alice sees %d messages, want bob's deletion to affect only bob", len(messages))
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	ts := newTestServer(t)
+	author := ts.signup("author@example.com")
+	fan := ts.signup("fan@example.com")
+	ts.chirp(fan, "hello @author@example.com!")
+
+	type notificationList struct {
+		UnreadCount   int64               `json:"unread_count"`
+		Notifications []NotificationGroup `json:"notifications"`
+	}
+	var list notificationList
+	ts.call("GET", "/api/notifications", author.Token, nil, http.StatusOK).decode(t, &list)
+	if list.UnreadCount != 1 || len(list.Notifications) != 1 || list.Notifications[0].Type != notificationTypeMention {
+		t.Fatalf("notifications = %+v, want one unread mention", list)
+	}
+	var page notificationList
+	ts.call("GET", "/api/notifications?offset=1", author.Token, nil, http.StatusOK).decode(t, &page)
+	if len(page.Notifications) != 0 {
+		t.Errorf("second page = %+v, want nothing", page.Notifications)
+	}
+	// An offset past 32 bits would wrap around on its way to the database.
+	ts.call("GET", "/api/notifications?offset=3000000000", author.Token, nil, http.StatusBadRequest)
+
+	ts.call("POST", "/api/notifications/read", author.Token, map[string]any{"ids": []uuid.UUID{}}, http.StatusBadRequest)
+	ts.call("POST", "/api/notifications/read", author.Token, map[string]any{"ids": list.Notifications[0].IDs}, http.StatusNoContent)
+	ts.call("GET", "/api/notifications", author.Token, nil, http.StatusOK).decode(t, &list)
+	if list.UnreadCount != 0 {
+		t.Errorf("unread count = %d after marking read, want 0", list.UnreadCount)
+	}
+
+	ts.chirp(fan, "@author@example.com again")
+	ts.call("POST", "/api/notifications/read-all", author.Token, nil, http.StatusNoContent)
+	ts.call("GET", "/api/notifications", author.Token, nil, http.StatusOK).decode(t, &list)
+	if list.UnreadCount != 0 {
+		t.Errorf("unread count = %d after read-all, want 0", list.UnreadCount)
+	}
+
+	var prefs map[string]bool
+	ts.call("GET", "/api/notifications/preferences", author.Token, nil, http.StatusOK).decode(t, &prefs)
+	if !prefs[notificationTypeMention] {
+		t.Errorf("preferences = %v, want mentions on by default", prefs)
+	}
+	ts.call("PUT", "/api/notifications/preferences", author.Token, map[string]bool{"pokes": false}, http.StatusBadRequest)
+	// Nothing sends likes yet, so there's nothing to switch off.
+	ts.call("PUT", "/api/notifications/preferences", author.Token, map[string]bool{"like": false}, http.StatusBadRequest)
+	ts.call("PUT", "/api/notifications/preferences", author.Token, map[string]bool{notificationTypeMention: false}, http.StatusOK).decode(t, &prefs)
+	if prefs[notificationTypeMention] {
+		t.Errorf("preferences = %v, want mentions off", prefs)
+	}
+
+	ts.chirp(fan, "@author@example.com are you there?")
+	ts.call("GET", "/api/notifications", author.Token, nil, http.StatusOK).decode(t, &list)
+	if list.UnreadCount != 0 {
+		t.Errorf("unread count = %d with mentions off, want 0", list.UnreadCount)
+	}
+}
+
+func TestPolkaWebhook(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signup("customer@example.com")
+	event := map[string]any{
+		"event": "user.upgraded",
+		"data":  map[string]string{"user_id": user.ID.String()},
+	}
+
+	req := ts.newRequest("POST", "/api/polka/webhooks", "", event)
+	ts.send(req, http.StatusUnauthorized)
+	req = ts.newRequest("POST", "/api/polka/webhooks", "", event)
+	req.Header.Set("Authorization", "ApiKey wrong")
+	ts.send(req, http.StatusUnauthorized)
+	req = ts.newRequest("POST", "/api/polka/webhooks", "", event)
+	req.Header.Set("Authorization", "ApiKey "+testPolkaKey)
+	ts.send(req, http.StatusNoContent)
+
+	var resp struct {
+		IsChirpyRed bool `json:"is_chirpy_red"`
+	}
+	ts.call("POST", "/api/login", "", map[string]string{"email": user.Email, "password": testPassword}, http.StatusOK).decode(t, &resp)
+	if !resp.IsChirpyRed {
+		t.Error("user wasn't upgraded to Chirpy Red")
+	}
+}
+
+func TestAccountExportAndDeletion(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signup("leaving@example.com")
+	ts.chirp(user, "goodbye")
+
+	resp := ts.call("GET", "/api/users/me/export", user.Token, nil, http.StatusOK)
+	archive, err := zip.NewReader(bytes.NewReader(resp.body), int64(len(resp.body)))
+	if err != nil {
+		t.Fatalf("export isn't a zip: %v", err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "profile.json,chirps.json" {
+		t.Errorf("export contains %v, want profile.json and chirps.json", names)
+	}
+
+	ts.call("DELETE", "/api/users/me", user.Token, map[string]string{"password": "wrong"}, http.StatusUnauthorized)
+	ts.call("DELETE", "/api/users/me", user.Token, map[string]string{"password": testPassword}, http.StatusOK)
+	ts.call("POST", "/api/refresh", user.RefreshToken, nil, http.StatusUnauthorized)
+	var chirps []Chirp
+	ts.call("GET", "/api/chirps", "", nil, http.StatusOK).decode(t, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("deleted user's chirps are still listed: %+v", chirps)
+	}
+
+	// Logging in during the grace period restores the account.
+	ts.login(user.Email)
+	ts.call("GET", "/api/chirps", "", nil, http.StatusOK).decode(t, &chirps)
+	if len(chirps) != 1 {
+		t.Errorf("restored user's chirps = %d, want 1", len(chirps))
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	// Signing up with the admin email proves nothing until it's verified.
+	admin := ts.signup(testAdminEmail)
+	ts.call("GET", "/admin/roles", admin.Token, nil, http.StatusForbidden)
+	// An account whose first email never arrived, or that predates
+	// verification, can ask for another; the first token stops working.
+	firstToken := ts.mail.lastToken(t, testAdminEmail)
+	ts.call("POST", "/api/users/verify/resend", "", nil, http.StatusUnauthorized)
+	ts.call("POST", "/api/users/verify/resend", admin.Token, nil, http.StatusNoContent)
+	ts.call("POST", "/api/users/verify", "", map[string]string{"token": firstToken}, http.StatusBadRequest)
+	ts.call("POST", "/api/users/verify", "", map[string]string{"token": ts.mail.lastToken(t, testAdminEmail)}, http.StatusNoContent)
+	ts.call("POST", "/api/users/verify/resend", admin.Token, nil, http.StatusConflict)
+	admin = ts.login(testAdminEmail)
+	user := ts.signup("user@example.com")
+
+	ts.call("GET", "/admin/roles", user.Token, nil, http.StatusForbidden)
+	var roles []Role
+	ts.call("GET", "/admin/roles", admin.Token, nil, http.StatusOK).decode(t, &roles)
+	if len(roles) != 2 {
+		t.Errorf("roles = %+v, want admin and moderator", roles)
+	}
+
+	rolesPath := "/admin/users/" + user.ID.String() + "/roles"
+	ts.call("POST", rolesPath, admin.Token, map[string]string{"role": "overlord"}, http.StatusBadRequest)
+	ts.call("POST", rolesPath, admin.Token, map[string]string{"role": "moderator"}, http.StatusNoContent)
+	var userRoles []string
+	ts.call("GET", rolesPath, admin.Token, nil, http.StatusOK).decode(t, &userRoles)
+	if len(userRoles) != 1 || userRoles[0] != "moderator" {
+		t.Errorf("user roles = %v, want [moderator]", userRoles)
+	}
+	ts.call("DELETE", rolesPath+"/moderator", admin.Token, nil, http.StatusNoContent)
+	ts.call("DELETE", rolesPath+"/moderator", admin.Token, nil, http.StatusNotFound)
+	ts.call("GET", "/admin/users/"+uuid.NewString()+"/roles", admin.Token, nil, http.StatusNotFound)
+
+	ts.call("GET", "/admin/metrics", user.Token, nil, http.StatusForbidden)
+	resp := ts.call("GET", "/admin/metrics", admin.Token, nil, http.StatusOK)
+	if !strings.Contains(string(resp.body), "Welcome, Chirpy Admin") {
+		t.Errorf("GET /admin/metrics = %q, want the dashboard", resp.body)
+	}
+
+	ts.call("POST", "/admin/reset", user.Token, nil, http.StatusForbidden)
+	ts.call("POST", "/admin/reset", admin.Token, nil, http.StatusOK)
+	ts.call("POST", "/api/login", "", map[string]string{"email": user.Email, "password": testPassword}, http.StatusUnauthorized)
+}
+
+// idleDriver hands out connections that do nothing, so a real *sql.DB can
+// report connection stats without a database behind it.
+type idleDriver struct{}
+
+func (idleDriver) Open(string) (driver.Conn, error) { return idleConn{}, nil }
+
+type idleConn struct{}
+
+func (idleConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (idleConn) Close() error                        { return nil }
+func (idleConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func init() {
+	sql.Register("chirpy-idle", idleDriver{})
+}
+
+func TestAdminMetricsDatabaseConnections(t *testing.T) {
+	db, err := sql.Open("chirpy-idle", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Hold one connection and leave another idle.
+	held, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	idle, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle.Close()
+
+	cfg := &apiConfig{metrics: metrics.New(db)}
+	rec := httptest.NewRecorder()
+	cfg.adminMetricsHandler(rec, httptest.NewRequest("GET", "/admin/metrics", nil))
+	for _, want := range []string{
+		"<td>Open database connections</td><td>2</td>",
+		"<td>Database connections in use</td><td>1</td>",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("dashboard doesn't contain %s:\n%s", want, rec.Body)
+		}
+	}
+}
+
+// schemaDriver answers every query with the number it was opened with,
+// standing in for the migration version in goose_db_version.
+type schemaDriver struct{}
+
+func (schemaDriver) Open(name string) (driver.Conn, error) { return schemaConn(name), nil }
+
+type schemaConn string
+
+func (c schemaConn) Prepare(string) (driver.Stmt, error) { return schemaStmt(c), nil }
+func (schemaConn) Close() error                          { return nil }
+func (schemaConn) Begin() (driver.Tx, error)             { return nil, errors.New("not supported") }
+
+type schemaStmt string
+
+func (schemaStmt) Close() error  { return nil }
+func (schemaStmt) NumInput() int { return -1 }
+func (schemaStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s schemaStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &schemaRows{version: string(s)}, nil
+}
+
+type schemaRows struct {
+	version string
+	done    bool
+}
+
+func (*schemaRows) Columns() []string { return []string{"version"} }
+func (*schemaRows) Close() error      { return nil }
+func (r *schemaRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	version, err := strconv.ParseInt(r.version, 10, 64)
+	dest[0] = version
+	return err
+}
+
+func init() {
+	sql.Register("chirpy-schema", schemaDriver{})
+}
+
+// checkReadiness calls the readiness handler directly and decodes its response.
+func checkReadiness(t *testing.T, cfg *apiConfig) (int, string, map[string]HealthCheck) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	cfg.handlerReadiness(rec, httptest.NewRequest("GET", "/admin/readyz", nil))
+	var body struct {
+		Status string                 `json:"status"`
+		Checks map[string]HealthCheck `json:"checks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("couldn't decode %s: %v", rec.Body, err)
+	}
+	return rec.Code, body.Status, body.Checks
+}
+
+func TestReadinessSchemaVersion(t *testing.T) {
+	latest, err := schema.LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		version int64
+		want    int
+	}{
+		{"behind", latest - 1, http.StatusServiceUnavailable},
+		{"current", latest, http.StatusOK},
+		// Another replica has already run the next release's migrations.
+		{"ahead", latest + 1, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := sql.Open("chirpy-schema", strconv.FormatInt(tt.version, 10))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			code, _, checks := checkReadiness(t, &apiConfig{db: db, workers: newWorkerHealth()})
+			if code != tt.want {
+				t.Errorf("readiness at migration %d = %d, want %d: %+v", tt.version, code, tt.want, checks)
+			}
+			if got := checks["migrations"]; !got.Critical || (got.Status == healthStatusOK) != (tt.want == http.StatusOK) {
+				t.Errorf("migrations check = %+v", got)
+			}
+		})
+	}
+}
+
+func TestReadinessWorkers(t *testing.T) {
+	cfg := &apiConfig{workers: newWorkerHealth()}
+	cfg.workers.register("sweeper", time.Minute)
+	if code, status, _ := checkReadiness(t, cfg); code != http.StatusOK || status != healthStatusOK {
+		t.Fatalf("readiness with a new worker = %d %q, want 200 ok", code, status)
+	}
+
+	// A failing worker degrades the server without taking it out of
+	// rotation.
+	cfg.workers.record("sweeper", errors.New("database is down"))
+	code, status, checks := checkReadiness(t, cfg)
+	if code != http.StatusOK || status != healthStatusDegraded {
+		t.Errorf("readiness with a failed run = %d %q, want 200 degraded", code, status)
+	}
+	if got := checks["worker:sweeper"]; got.Status != healthStatusFail || got.Error != "database is down" {
+		t.Errorf("worker check = %+v", got)
+	}
+
+	cfg.workers.record("sweeper", nil)
+	if _, status, _ := checkReadiness(t, cfg); status != healthStatusOK {
+		t.Errorf("readiness after a good run = %q, want ok", status)
+	}
+
+	// So does one that has missed two runs.
+	cfg.workers.workers["sweeper"].lastRun = time.Now().Add(-3 * time.Minute)
+	code, status, checks = checkReadiness(t, cfg)
+	if code != http.StatusOK || status != healthStatusDegraded {
+		t.Errorf("readiness with a stuck worker = %d %q, want 200 degraded", code, status)
+	}
+	if got := checks["worker:sweeper"]; got.Status != healthStatusFail || !strings.HasPrefix(got.Error, "last ran ") {
+		t.Errorf("worker check = %+v", got)
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp := ts.call("POST", "/api/users", "", map[string]any{"email": "a@example.com", "password": testPassword, "admin": true}, http.StatusBadRequest)
+	var envelope struct {
+		Error     string `json:"error"`
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+	}
+	resp.decode(t, &envelope)
+	if envelope.Code != "unknown_field" || envelope.RequestID == "" {
+		t.Errorf("error envelope = %+v, want unknown_field with a request ID", envelope)
+	}
+	if got := resp.header.Get(requestIDHeader); got != envelope.RequestID {
+		t.Errorf("%s header = %q, want %q", requestIDHeader, got, envelope.RequestID)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	ts := newTestServer(t, func(conf *config.Config) {
+		conf.RateLimitAuth = ratelimit.Policy{Limit: 3, Period: time.Minute}
+		conf.RateLimitReads = ratelimit.Policy{Limit: 2, Period: time.Minute}
+	})
+	user := ts.signup("user@example.com")
+
+	// The signup and login above used two of the three auth requests.
+	resp := ts.call("POST", "/api/login", "", map[string]string{"email": user.Email, "password": "wrong"}, http.StatusUnauthorized)
+	if got := resp.header.Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+	if got := resp.header.Get("RateLimit-Policy"); got != "3;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want 3;w=60", got)
+	}
+	resp = ts.call("POST", "/api/login", "", map[string]string{"email": user.Email, "password": testPassword}, http.StatusTooManyRequests)
+	// A request comes back every 20s, less however long the bcrypt calls
+	// above took.
+	if got, err := strconv.Atoi(resp.header.Get("Retry-After")); err != nil || got < 1 || got > 20 {
+		t.Errorf("Retry-After = %q, want 1 to 20", resp.header.Get("Retry-After"))
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	resp.decode(t, &body)
+	if body.Code != "rate_limited" {
+		t.Errorf("code = %q, want rate_limited", body.Code)
+	}
+
+	// Reads are counted per user, so another client isn't held back by
+	// this one.
+	ts.call("GET", "/api/chirps", user.Token, nil, http.StatusOK)
+	ts.call("GET", "/api/chirps", user.Token, nil, http.StatusOK)
+	ts.call("GET", "/api/chirps", user.Token, nil, http.StatusTooManyRequests)
+	ts.call("GET", "/api/chirps", "", nil, http.StatusOK)
+
+	// Auth endpoints are limited by IP whatever token comes along, so
+	// accounts don't each bring their own bucket for guessing passwords.
+	ts = newTestServer(t, func(conf *config.Config) {
+		conf.RateLimitAuth = ratelimit.Policy{Limit: 5, Period: time.Minute}
+	})
+	walt, jesse := ts.signup("walt@example.com"), ts.signup("jesse@example.com")
+	guess := map[string]string{"email": user.Email, "password": "wrong"}
+	ts.call("POST", "/api/login", walt.Token, guess, http.StatusUnauthorized)
+	ts.call("POST", "/api/login", jesse.Token, guess, http.StatusTooManyRequests)
+
+	// Health checks aren't limited at all.
+	resp = ts.call("GET", "/admin/healthz", "", nil, http.StatusOK)
+	if got := resp.header.Get("RateLimit-Limit"); got != "" {
+		t.Errorf("RateLimit-Limit = %q on a health check, want none", got)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signup("user@example.com")
+	post := func(key, body string, wantStatus int) testResponse {
+		req := ts.newRequest("POST", "/api/chirps", user.Token, map[string]string{"body": body})
+		req.Header.Set("Idempotency-Key", key)
+		return ts.send(req, wantStatus)
+	}
+
+	var first, retried Chirp
+	post("key-1", "hello", http.StatusCreated).decode(t, &first)
+	resp := post("key-1", "hello", http.StatusCreated)
+	resp.decode(t, &retried)
+	if retried.ID != first.ID || resp.header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry = %+v, want the first chirp %s replayed", retried, first.ID)
+	}
+	post("key-1", "something else", http.StatusUnprocessableEntity)
+	post("key-2", "hello", http.StatusCreated)
+
+	// Client errors are replayed too; only server errors free the key.
+	post("key-3", "", http.StatusUnprocessableEntity)
+	post("key-3", "", http.StatusUnprocessableEntity)
+
+	var chirps []Chirp
+	ts.call("GET", "/api/chirps", "", nil, http.StatusOK).decode(t, &chirps)
+	if len(chirps) != 2 {
+		t.Errorf("got %d chirps, want 2", len(chirps))
+	}
+
+	// Keys belong to the user who sent them.
+	other := ts.signup("other@example.com")
+	req := ts.newRequest("POST", "/api/chirps", other.Token, map[string]string{"body": "hello"})
+	req.Header.Set("Idempotency-Key", "key-1")
+	var otherChirp Chirp
+	ts.send(req, http.StatusCreated).decode(t, &otherChirp)
+	if otherChirp.ID == first.ID || otherChirp.UserID != other.ID {
+		t.Errorf("another user's request with the same key = %+v, want their own chirp", otherChirp)
+	}
+
+	// A password change returns new tokens, which mustn't be stored.
+	req = ts.newRequest("PATCH", "/api/users", user.Token, map[string]string{
+		"password":         "tread lightly",
+		"current_password": testPassword,
+	})
+	req.Header.Set("Idempotency-Key", "password")
+	ts.send(req, http.StatusOK)
+	_, err := ts.cfg.databaseQueries.GetIdempotencyKey(context.Background(), database.GetIdempotencyKeyParams{
+		Scope: "user:" + user.ID.String(),
+		Key:   "password",
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetIdempotencyKey() error = %v, want the password change not to be stored", err)
+	}
+}
+
+func TestIdempotencyKeyInFlight(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signup("user@example.com")
+	body := map[string]string{"body": "hello"}
+
+	// Pretend another request with the key is still running.
+	req := ts.newRequest("POST", "/api/chirps", user.Token, body)
+	b, _ := json.Marshal(body)
+	now := time.Now().UTC()
+	_, err := ts.cfg.databaseQueries.ClaimIdempotencyKey(context.Background(), database.ClaimIdempotencyKeyParams{
+		Scope:       "user:" + user.ID.String(),
+		Key:         "busy",
+		Fingerprint: requestFingerprint(req, b),
+		LockedAt:    now,
+		ExpiresAt:   now.Add(idempotencyKeyTTL),
+		StaleBefore: now.Add(-idempotencyLockTimeout),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Idempotency-Key", "busy")
+	resp := ts.send(req, http.StatusConflict)
+	if resp.header.Get("Retry-After") == "" {
+		t.Error("409 for a key in flight has no Retry-After")
+	}
+
+	// Concurrent duplicates create one chirp between them.
+	var wg sync.WaitGroup
+	statuses := make(chan int, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := ts.newRequest("POST", "/api/chirps", user.Token, body)
+			req.Header.Set("Idempotency-Key", "race")
+			resp, err := ts.srv.Client().Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		if status != http.StatusCreated && status != http.StatusConflict {
+			t.Errorf("concurrent duplicate got %d, want 201 or 409", status)
+		}
+	}
+	var chirps []Chirp
+	ts.call("GET", "/api/chirps", "", nil, http.StatusOK).decode(t, &chirps)
+	if len(chirps) != 1 {
+		t.Errorf("got %d chirps from concurrent duplicates, want 1", len(chirps))
+	}
+}
+
+func TestIdempotencyKeyTakeover(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signup("user@example.com")
+	body := map[string]string{"body": "hello"}
+
+	// Pretend a request with the key has been running so long it looks
+	// abandoned.
+	req := ts.newRequest("POST", "/api/chirps", user.Token, body)
+	b, _ := json.Marshal(body)
+	id := database.GetIdempotencyKeyParams{Scope: "user:" + user.ID.String(), Key: "slow"}
+	stale := time.Now().UTC().Add(-2 * idempotencyLockTimeout)
+	oldToken := uuid.New()
+	_, err := ts.cfg.databaseQueries.ClaimIdempotencyKey(context.Background(), database.ClaimIdempotencyKeyParams{
+		Scope:       id.Scope,
+		Key:         id.Key,
+		Fingerprint: requestFingerprint(req, b),
+		LockedAt:    stale,
+		ExpiresAt:   stale.Add(idempotencyKeyTTL),
+		LockToken:   oldToken,
+		StaleBefore: stale.Add(-idempotencyLockTimeout),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Idempotency-Key", "slow")
+	first := ts.send(req, http.StatusCreated)
+
+	// The original request finishing late must not touch the new owner's key.
+	completed, err := ts.cfg.databaseQueries.CompleteIdempotencyKey(context.Background(), database.CompleteIdempotencyKeyParams{
+		Scope:               id.Scope,
+		Key:                 id.Key,
+		LockToken:           oldToken,
+		ResponseStatus:      http.StatusBadRequest,
+		ResponseContentType: "text/plain",
+		ResponseBody:        []byte("stale"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed != 0 {
+		t.Errorf("CompleteIdempotencyKey() with the old token updated %d rows, want 0", completed)
+	}
+	err = ts.cfg.databaseQueries.DeleteIdempotencyKey(context.Background(), database.DeleteIdempotencyKeyParams{
+		Scope:     id.Scope,
+		Key:       id.Key,
+		LockToken: oldToken,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = ts.newRequest("POST", "/api/chirps", user.Token, body)
+	req.Header.Set("Idempotency-Key", "slow")
+	replay := ts.send(req, http.StatusCreated)
+	if replay.header.Get("Idempotent-Replayed") != "true" {
+		t.Error("retry after the take-over wasn't replayed")
+	}
+	if !bytes.Equal(replay.body, first.body) {
+		t.Errorf("replayed %s, want %s", replay.body, first.body)
+	}
+}
+
+func TestChirpConditionalRequests(t *testing.T) {
+	ts := newTestServer(t)
+	author := ts.signup("author@example.com")
+	chirp := ts.chirp(author, "hello")
+	path := "/api/chirps/" + chirp.ID.String()
+
+	resp := ts.call("GET", path, "", nil, http.StatusOK)
+	etag := resp.header.Get("ETag")
+	if etag == "" || resp.header.Get("Last-Modified") == "" {
+		t.Fatalf("headers = %v, want ETag and Last-Modified", resp.header)
+	}
+	if got := resp.header.Get("Cache-Control"); !strings.HasPrefix(got, "public") {
+		t.Errorf("anonymous Cache-Control = %q, want public", got)
+	}
+	if got := ts.call("GET", path, author.Token, nil, http.StatusOK).header.Get("Cache-Control"); got != "private, no-cache" {
+		t.Errorf("signed-in Cache-Control = %q, want private, no-cache", got)
+	}
+
+	req := ts.newRequest("GET", path, "", nil)
+	req.Header.Set("If-None-Match", `"stale", `+etag)
+	if resp := ts.send(req, http.StatusNotModified); len(resp.body) != 0 || resp.header.Get("ETag") != etag {
+		t.Errorf("304 = %q with ETag %q, want no body and the same ETag", resp.body, resp.header.Get("ETag"))
+	}
+	req = ts.newRequest("GET", path, "", nil)
+	req.Header.Set("If-Modified-Since", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	ts.send(req, http.StatusNotModified)
+	req = ts.newRequest("GET", path, "", nil)
+	req.Header.Set("If-Modified-Since", chirp.UpdatedAt.Add(-time.Hour).UTC().Format(http.TimeFormat))
+	ts.send(req, http.StatusOK)
+
+	// The list's ETag changes when a chirp is added.
+	listETag := ts.call("GET", "/api/chirps", "", nil, http.StatusOK).header.Get("ETag")
+	req = ts.newRequest("GET", "/api/chirps", "", nil)
+	req.Header.Set("If-None-Match", listETag)
+	ts.send(req, http.StatusNotModified)
+	ts.chirp(author, "another")
+	req = ts.newRequest("GET", "/api/chirps", "", nil)
+	req.Header.Set("If-None-Match", listETag)
+	ts.send(req, http.StatusOK)
+
+	req = ts.newRequest("DELETE", path, author.Token, nil)
+	req.Header.Set("If-Match", `"stale"`)
+	ts.send(req, http.StatusPreconditionFailed)
+	req = ts.newRequest("DELETE", path, author.Token, nil)
+	req.Header.Set("If-Match", etag)
+	ts.send(req, http.StatusNoContent)
+}
+
+func TestCORSAndSecurityHeaders(t *testing.T) {
+	ts := newTestServer(t, func(conf *config.Config) {
+		conf.CORSAllowedOrigins = []string{"https://app.example.com"}
+		conf.CORSAllowCredentials = true
+	})
+
+	req := ts.newRequest("OPTIONS", "/api/chirps", "", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	resp := ts.send(req, http.StatusNoContent)
+	if got := resp.header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if resp.header.Get("Access-Control-Allow-Credentials") != "true" || resp.header.Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight headers = %v, want credentials allowed and cached for 10 minutes", resp.header)
+	}
+
+	req.Header.Set("Access-Control-Request-Headers", "X-Unknown")
+	ts.send(req, http.StatusForbidden)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Del("Access-Control-Request-Headers")
+	ts.send(req, http.StatusForbidden)
+
+	req = ts.newRequest("GET", "/api/chirps", "", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	resp = ts.send(req, http.StatusOK)
+	if got := resp.header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q for an unknown origin, want none", got)
+	}
+	req.Header.Set("Origin", "https://app.example.com")
+	resp = ts.send(req, http.StatusOK)
+	if !strings.Contains(resp.header.Get("Access-Control-Expose-Headers"), "ETag") {
+		t.Errorf("Access-Control-Expose-Headers = %q, want ETag exposed", resp.header.Get("Access-Control-Expose-Headers"))
+	}
+
+	// Publicly cacheable chirp reads must keep the CORS middleware's Vary:
+	// Origin, or a shared cache could hand one origin's CORS headers to
+	// another.
+	author := ts.signup("walt@example.com")
+	chirp := ts.chirp(author, "cached across origins")
+	req = ts.newRequest("GET", "/api/chirps/"+chirp.ID.String(), "", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	vary := strings.Join(ts.send(req, http.StatusOK).header.Values("Vary"), ", ")
+	for _, want := range []string{"Origin", "Authorization", "Cookie"} {
+		if !strings.Contains(vary, want) {
+			t.Errorf("GET /api/chirps/{id} Vary = %q, want %s", vary, want)
+		}
+	}
+
+	for _, header := range []string{"Content-Security-Policy", "X-Content-Type-Options", "X-Frame-Options"} {
+		if resp.header.Get(header) == "" {
+			t.Errorf("response has no %s header", header)
+		}
+	}
+	if got := resp.header.Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Strict-Transport-Security = %q over plain HTTP, want none", got)
+	}
+	req = ts.newRequest("GET", "/admin/healthz", "", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	if got := ts.send(req, http.StatusOK).header.Get("Strict-Transport-Security"); !strings.HasPrefix(got, "max-age=") {
+		t.Errorf("Strict-Transport-Security = %q behind an HTTPS proxy", got)
+	}
+}
+
+func TestCookieSessions(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.signup("user@example.com")
+	credentials := map[string]string{"email": user.Email, "password": testPassword}
+
+	ts.call("POST", "/api/login?cookies=sometimes", "", credentials, http.StatusBadRequest)
+	resp := ts.call("POST", "/api/login?cookies=all", "", credentials, http.StatusOK)
+	var login struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+		CSRFToken    string `json:"csrf_token"`
+	}
+	resp.decode(t, &login)
+	if login.Token != "" || login.RefreshToken != "" || login.CSRFToken == "" {
+		t.Errorf("login = %+v, want only a CSRF token in the body", login)
+	}
+	cookies := map[string]*http.Cookie{}
+	for _, c := range (&http.Response{Header: resp.header}).Cookies() {
+		cookies[c.Name] = c
+	}
+	access, refresh := cookies[auth.AccessTokenCookie], cookies[auth.RefreshTokenCookie]
+	if access == nil || refresh == nil {
+		t.Fatalf("cookies = %v, want access and refresh token cookies", cookies)
+	}
+	if !refresh.HttpOnly || !refresh.Secure || refresh.SameSite != http.SameSiteLaxMode {
+		t.Errorf("refresh cookie = %+v, want HttpOnly, Secure and SameSite=Lax", refresh)
+	}
+	withCookies := func(req *http.Request, cookies ...*http.Cookie) *http.Request {
+		for _, c := range cookies {
+			req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+		}
+		return req
+	}
+
+	// Reads only need the access cookie.
+	ts.send(withCookies(ts.newRequest("GET", "/api/notifications", "", nil), access), http.StatusOK)
+
+	// Writes also need the CSRF token for the session.
+	chirp := map[string]string{"body": "hello"}
+	ts.send(withCookies(ts.newRequest("POST", "/api/chirps", "", chirp), access, refresh), http.StatusForbidden)
+	req := withCookies(ts.newRequest("POST", "/api/chirps", "", chirp), access, refresh)
+	req.Header.Set(csrfHeader, ts.cfg.csrfToken("another-session"))
+	ts.send(req, http.StatusForbidden)
+	req = withCookies(ts.newRequest("POST", "/api/chirps", "", chirp), access, refresh)
+	req.Header.Set(csrfHeader, login.CSRFToken)
+	ts.send(req, http.StatusCreated)
+	// A bearer token isn't sent by browsers on their own, so it needs none.
+	ts.send(withCookies(ts.newRequest("POST", "/api/chirps", user.Token, chirp), access, refresh), http.StatusCreated)
+
+	// Changing the password ends every session, this one included, so the
+	// cookies and CSRF token are replaced, and no token reaches the body.
+	newPassword := "tread lightly"
+	req = withCookies(ts.newRequest("PATCH", "/api/users", "", map[string]string{
+		"password":         newPassword,
+		"current_password": testPassword,
+	}), access, refresh)
+	req.Header.Set(csrfHeader, login.CSRFToken)
+	resp = ts.send(req, http.StatusOK)
+	var changed struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+		CSRFToken    string `json:"csrf_token"`
+	}
+	resp.decode(t, &changed)
+	if changed.Token != "" || changed.RefreshToken != "" || changed.CSRFToken == "" || changed.CSRFToken == login.CSRFToken {
+		t.Errorf("password change = %+v, want only a new CSRF token in the body", changed)
+	}
+	oldRefresh := refresh
+	for _, c := range (&http.Response{Header: resp.header}).Cookies() {
+		cookies[c.Name] = c
+	}
+	access, refresh = cookies[auth.AccessTokenCookie], cookies[auth.RefreshTokenCookie]
+	if refresh.Value == oldRefresh.Value {
+		t.Fatal("password change didn't replace the refresh cookie")
+	}
+	req = withCookies(ts.newRequest("POST", "/api/refresh", "", nil), oldRefresh)
+	req.Header.Set(csrfHeader, login.CSRFToken)
+	ts.send(req, http.StatusUnauthorized)
+	login.CSRFToken = changed.CSRFToken
+	credentials["password"] = newPassword
+	req = withCookies(ts.newRequest("POST", "/api/chirps", "", chirp), access, refresh)
+	req.Header.Set(csrfHeader, login.CSRFToken)
+	ts.send(req, http.StatusCreated)
+
+	var fetched struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	ts.call("GET", "/api/csrf", "", nil, http.StatusUnauthorized)
+	ts.send(withCookies(ts.newRequest("GET", "/api/csrf", "", nil), refresh), http.StatusOK).decode(t, &fetched)
+	if fetched.CSRFToken != login.CSRFToken {
+		t.Errorf("GET /api/csrf = %q, want the token from login", fetched.CSRFToken)
+	}
+
+	req = withCookies(ts.newRequest("POST", "/api/refresh?cookies=all", "", nil), refresh)
+	req.Header.Set(csrfHeader, login.CSRFToken)
+	resp = ts.send(req, http.StatusOK)
+	if !strings.Contains(resp.header.Get("Set-Cookie"), auth.AccessTokenCookie+"=") {
+		t.Errorf("refresh didn't set a new access cookie: %v", resp.header)
+	}
+
+	req = withCookies(ts.newRequest("POST", "/api/revoke", "", nil), refresh)
+	req.Header.Set(csrfHeader, login.CSRFToken)
+	resp = ts.send(req, http.StatusNoContent)
+	for _, c := range (&http.Response{Header: resp.header}).Cookies() {
+		if c.MaxAge >= 0 {
+			t.Errorf("revoke left cookie %s set", c.Name)
+		}
+	}
+	req = withCookies(ts.newRequest("POST", "/api/refresh", "", nil), refresh)
+	req.Header.Set(csrfHeader, login.CSRFToken)
+	ts.send(req, http.StatusUnauthorized)
+
+	// Logging in again works whatever cookies are left behind.
+	ts.send(withCookies(ts.newRequest("POST", "/api/login?cookies=refresh", "", credentials), access, refresh), http.StatusOK)
+}
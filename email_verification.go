@@ -0,0 +1,14 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// sendEmailVerification mails a verification token to the address it
+// confirms. The service issues the token; sending it happens once the
+// transaction that created it has committed.
+func sendEmailVerification(ctx context.Context, m mailer, email, token string) error {
+	body := fmt.Sprintf("Confirm your email address by sending this token to POST /api/users/verify within 24 hours:\n\n%s", token)
+	return m.Send(ctx, email, "Verify your Chirpy email address", body)
+}
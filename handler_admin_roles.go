@@ -0,0 +1,164 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
+)
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+func (cfg *apiConfig) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	dbRoles, err := cfg.databaseQueries.ListRoles(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve roles", err)
+		return
+	}
+
+	roles := []Role{}
+	for _, role := range dbRoles {
+		roles = append(roles, Role{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: role.Permissions,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, roles)
+}
+
+func (cfg *apiConfig) listUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.parseExistingUserID(w, r)
+	if !ok {
+		return
+	}
+
+	roles, err := cfg.databaseQueries.ListUserRoles(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve user roles", err)
+		return
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	respondWithJSON(w, http.StatusOK, roles)
+}
+
+// Grants a role to a user. The user's next access token (at login or refresh)
+// carries the new permissions.
+func (cfg *apiConfig) grantRoleHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	userID, ok := cfg.parseExistingUserID(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	err := validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	v := validation.Validator{}
+	v.Required("role", params.Role)
+	if err := v.Err(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Role is required", err)
+		return
+	}
+
+	_, err = cfg.databaseQueries.GetRole(r.Context(), params.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Unknown role", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up role", err)
+		return
+	}
+
+	admin := authenticatedUserFromContext(r.Context())
+	granted, err := cfg.databaseQueries.GrantRole(r.Context(), database.GrantRoleParams{
+		UserID:    userID,
+		Role:      params.Role,
+		GrantedBy: uuid.NullUUID{UUID: admin.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't grant role", err)
+		return
+	}
+	if granted > 0 {
+		cfg.recordRoleChange(r, admin.ID, userID, moderationActionGrantRole, params.Role)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) revokeRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.parseExistingUserID(w, r)
+	if !ok {
+		return
+	}
+	role := r.PathValue("role")
+
+	admin := authenticatedUserFromContext(r.Context())
+	revoked, err := cfg.databaseQueries.RevokeRole(r.Context(), database.RevokeRoleParams{
+		UserID: userID,
+		Role:   role,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke role", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "User doesn't have that role", nil)
+		return
+	}
+	cfg.recordRoleChange(r, admin.ID, userID, moderationActionRevokeRole, role)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseExistingUserID resolves the {userID} path value to an existing user.
+// It writes the error response itself and returns false if anything is wrong.
+func (cfg *apiConfig) parseExistingUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return uuid.Nil, false
+	}
+	_, err = cfg.databaseQueries.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return uuid.Nil, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up user", err)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// Role changes are written to the same audit trail as moderation actions,
+// with the role name as the note.
+func (cfg *apiConfig) recordRoleChange(r *http.Request, adminID, userID uuid.UUID, action, role string) {
+	_, err := cfg.databaseQueries.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: adminID, Valid: true},
+		Action:       action,
+		TargetUserID: uuid.NullUUID{UUID: userID, Valid: true},
+		Note:         role,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Couldn't record role change", "action", action, "role", role, "target_user_id", userID, "error", err)
+	}
+}
@@ -1,15 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/dandytron/chirpy.git/internal/auth"
-	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/validation"
 	"github.com/google/uuid"
 )
 
@@ -21,12 +18,6 @@ type Chirp struct {
 	UserID    uuid.UUID `json:"user_id"`
 }
 
-var profaneWords = map[string]struct{}{
-	"kerfuffle": {},
-	"sharbert":  {},
-	"fornax":    {},
-}
-
 func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Body string `json:"body"`
@@ -37,45 +28,33 @@ func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtsecret)
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
 		return
 	}
-	log.Printf("Validated JWT, user ID: %v", userID)
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err = validation.DecodeJSON(w, r, &params)
 	if err != nil {
-		// an error will be thrown if the JSON is invalid or has the wrong types
-		// any missing fields will simply have their values in the struct set to their zero value
-		// send response "error": "Something went wrong"
-		log.Printf("Error decoding parameters: %s", err)
-		respondWithError(w, http.StatusBadRequest, "Something went wrong", err)
-		// send response "error": "Something went wrong"
+		// an error will be thrown if the JSON is invalid, has the wrong types or unknown fields
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
 		return
 	}
 
-	// Check to make sure the Chirp isn't too long.
-	const maxChirpLength = 140
-	is_valid := isChirpTooLong(params.Body, maxChirpLength)
-	if !is_valid {
-		err = errors.New("this chirp is too long")
-		respondWithError(w, http.StatusBadRequest, "Something went wrong:", err)
+	// The service checks the Chirp isn't empty or too long and scrubs it.
+	newChirp, err := cfg.service.CreateChirp(r.Context(), userID, params.Body)
+	var vErr *validation.Error
+	if errors.As(err, &vErr) {
+		respondWithError(w, http.StatusUnprocessableEntity, "Invalid chirp", err)
 		return
 	}
-
-	// run chirp through the scrubber
-	scrubbed_chirp := chirpScrubber(params.Body)
-	newChirp, err := cfg.databaseQueries.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   scrubbed_chirp,
-		UserID: userID,
-	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating chirp:", err)
 		return
 	}
+	cfg.metrics.ChirpsCreated.Inc()
+	cfg.notifyMentions(r.Context(), newChirp)
 
 	chirp := Chirp{
 		ID:        newChirp.ID,
@@ -88,20 +67,3 @@ func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request)
 	// send JSON response with scrubbed chirp, status 200
 	respondWithJSON(w, http.StatusCreated, chirp)
 }
-
-// Helper function, checks to see if a 'chirp' is too long
-func isChirpTooLong(chirp string, maxLength int) bool {
-	return len(chirp) <= maxLength
-}
-
-// Assuming the length validation passed, replace any of the following words in the Chirp with the static 4-character string ****
-func chirpScrubber(chirp string) string {
-	split_chirp := strings.Split(chirp, " ")
-	for i, word := range split_chirp {
-		loweredWord := strings.ToLower(word)
-		if _, ok := profaneWords[loweredWord]; ok {
-			split_chirp[i] = "****"
-		}
-	}
-	return strings.Join(split_chirp, " ")
-}
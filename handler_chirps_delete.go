@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/service"
 	"github.com/google/uuid"
 )
 
@@ -19,47 +21,48 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// check for An access token in the header
+	// Get the access token from the header or the access cookie.
 	// If the access token is malformed or missing, respond with a 401 status code.
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		respondWithError(w, http.StatusUnauthorized, "Access token not found or malformed", nil)
-		return
-	}
-
-	// Extract the actual token from the "Bearer " prefix
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		respondWithError(w, http.StatusUnauthorized, "Access token not found or malformed", err)
 		return
 	}
-	// Validate the JWT, grab the userID
-	userID, err := auth.ValidateJWT(token, cfg.jwtsecret)
+	// Validate the JWT, grab the userID and what they're allowed to do
+	userID, access, err := auth.ValidateJWTAccess(token, cfg.config.JWTSecret)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Could not validate token", err)
 		return
 	}
 
-	// Only allow the deletion of a chirp if the user is the author of the chirp.
-	// First, retrieve chirp
-	chirpToDelete, err := cfg.databaseQueries.RetrieveSingleChirp(r.Context(), chirpID)
-	if err != nil {
+	// Only the author may delete a chirp, or a moderator, in which case the
+	// deletion goes on the moderation audit trail. With If-Match, the chirp
+	// must also not have changed since the client last read it.
+	var unchanged func(database.Chirp) bool
+	if r.Header.Get("If-Match") != "" {
+		unchanged = func(chirp database.Chirp) bool {
+			return !preconditionFailed(r, chirpETag(chirp))
+		}
+	}
+	err = cfg.service.DeleteChirp(r.Context(), userID, access, chirpID, unchanged)
+	if errors.Is(err, service.ErrNotFound) {
 		respondWithError(w, http.StatusNotFound, "Chirp to delete not found", err)
 		return
 	}
-
-	// Check if the user ID from the token matches the author ID of the chirp - if not, return 403
-	if userID != chirpToDelete.UserID {
+	if errors.Is(err, service.ErrForbidden) {
 		respondWithError(w, http.StatusForbidden, "User mismatch, unauthorized to delete", nil)
 		return
 	}
-
+	if errors.Is(err, service.ErrChangedSince) {
+		respondWithError(w, http.StatusPreconditionFailed, "Chirp has changed since it was read", nil)
+		return
+	}
 	//If cannot be deleted, return a 500 (Internal Server Error) status code.
-	err = cfg.databaseQueries.DeleteChirps(r.Context(), chirpID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not delete chirp", nil)
+		respondWithError(w, http.StatusInternalServerError, "Could not delete chirp", err)
 		return
 	}
+
 	//If the chirp is deleted successfully, return a 204 status code.
 
 	w.WriteHeader(http.StatusNoContent)
@@ -1,19 +1,43 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
 	"github.com/google/uuid"
 )
 
 // Handler to retrieve all chirps in the database
 
 func (cfg *apiConfig) retrieveAllChirpsHandler(w http.ResponseWriter, r *http.Request) {
-	chirps, err := cfg.databaseQueries.RetrieveAllChirps(r.Context())
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	// Chirps from users who blocked the viewer, or whom the viewer blocked or muted,
+	// are filtered out by the query itself.
+	chirps, err := cfg.databaseQueries.RetrieveAllChirps(r.Context(), viewerID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not retrieve chirps: ", err)
 		return
 	}
+
+	// No Last-Modified here: a chirp being deleted changes the list without
+	// making it any newer, so only the ETag can tell.
+	etag := chirpsETag(chirps)
+	setChirpCacheHeaders(w, viewerID == uuid.Nil, etag, time.Time{})
+	if notModified(r, etag, time.Time{}) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	var chirpsSlice []Chirp
 	for _, chirp := range chirps {
 		retrievedChirp := Chirp{
@@ -40,12 +64,34 @@ func (cfg *apiConfig) retrieveSingleChirpHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	chirp, err := cfg.databaseQueries.RetrieveSingleChirp(r.Context(), chirpID)
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	// A chirp whose author blocked the viewer looks exactly like a missing one.
+	var chirp database.Chirp
+	if viewerID == uuid.Nil {
+		chirp, err = cfg.publicChirp(r.Context(), chirpID)
+	} else {
+		chirp, err = cfg.databaseQueries.RetrieveVisibleChirp(r.Context(), database.RetrieveVisibleChirpParams{
+			ID:       chirpID,
+			ViewerID: viewerID,
+		})
+	}
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Could not retrieve chirp: ", err)
 		return
 	}
 
+	etag := chirpETag(chirp)
+	setChirpCacheHeaders(w, viewerID == uuid.Nil, etag, chirp.UpdatedAt)
+	if notModified(r, etag, chirp.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	retrievedChirp := Chirp{
 		ID:        chirp.ID,
 		CreatedAt: chirp.CreatedAt,
@@ -56,3 +102,39 @@ func (cfg *apiConfig) retrieveSingleChirpHandler(w http.ResponseWriter, r *http.
 	respondWithJSON(w, http.StatusOK, retrievedChirp)
 
 }
+
+// publicChirp finds a chirp for an anonymous reader, who can see any chirp
+// that isn't hidden and whose author hasn't deleted their account. Unlike
+// RetrieveVisibleChirp, both lookups are cached, which is what keeps popular
+// chirps from hitting the database on every request.
+func (cfg *apiConfig) publicChirp(ctx context.Context, chirpID uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.databaseQueries.RetrieveSingleChirp(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.HiddenAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	author, err := cfg.databaseQueries.GetUserByID(ctx, chirp.UserID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if author.DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+// viewerID returns the ID of the user making a request to a public endpoint,
+// or uuid.Nil for anonymous requests. A token that is present but invalid is
+// still an error, so a broken client doesn't silently see unfiltered results.
+func (cfg *apiConfig) viewerID(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if errors.Is(err, auth.ErrNoAuthHeaderIncluded) {
+		return uuid.Nil, nil
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return auth.ValidateJWT(token, cfg.config.JWTSecret)
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
+)
+
+// Direct messages live in their own tables, separate from chirps, so they can
+// never leak into the public chirp listings.
+const (
+	maxConversationMembers = 10
+	maxMessageLength       = 1000
+)
+
+type Conversation struct {
+	ID          uuid.UUID   `json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	MemberIDs   []uuid.UUID `json:"member_ids"`
+	LastMessage *Message    `json:"last_message"`
+	UnreadCount int64       `json:"unread_count"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+// Starts a conversation between the caller and the given members. A one-to-one
+// conversation that already exists is returned instead of creating a duplicate.
+func (cfg *apiConfig) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	params := parameters{}
+	err = validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// The caller is always a member; drop them and any duplicates from the list.
+	var others []uuid.UUID
+	for _, id := range params.MemberIDs {
+		if id != userID && !slices.Contains(others, id) {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		respondWithError(w, http.StatusBadRequest, "A conversation needs at least one other member", nil)
+		return
+	}
+	if len(others)+1 > maxConversationMembers {
+		respondWithError(w, http.StatusBadRequest, "Too many conversation members", nil)
+		return
+	}
+
+	conversation, created, err := cfg.service.StartConversation(r.Context(), userID, others)
+	if errors.Is(err, service.ErrNotFound) {
+		respondWithError(w, http.StatusBadRequest, "One or more members don't exist", nil)
+		return
+	}
+	if errors.Is(err, service.ErrForbidden) {
+		respondWithError(w, http.StatusForbidden, "You can't start a conversation with these users", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create conversation", err)
+		return
+	}
+
+	members := append([]uuid.UUID{userID}, others...)
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+	respondWithJSON(w, status, Conversation{
+		ID:        conversation.ID,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+		MemberIDs: members,
+	})
+}
+
+// Lists the caller's conversations, most recently active first, each with its
+// last visible message and the number of unread messages.
+func (cfg *apiConfig) listConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	rows, err := cfg.databaseQueries.ListConversations(r.Context(), database.ListConversationsParams{
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve conversations", err)
+		return
+	}
+
+	conversations := []Conversation{}
+	for _, row := range rows {
+		conversation := Conversation{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			MemberIDs:   row.MemberIds,
+			UnreadCount: row.UnreadCount,
+		}
+		if row.LastMessageID.Valid {
+			conversation.LastMessage = &Message{
+				ID:             row.LastMessageID.UUID,
+				CreatedAt:      row.LastMessageCreatedAt.Time,
+				ConversationID: row.ID,
+				SenderID:       row.LastMessageSenderID.UUID,
+				Body:           row.LastMessageBody.String,
+			}
+		}
+		conversations = append(conversations, conversation)
+	}
+	respondWithJSON(w, http.StatusOK, conversations)
+}
+
+// Returns a page of messages, newest first, and marks the conversation as read.
+func (cfg *apiConfig) listMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if !cfg.requireConversationMember(w, r, conversationID, userID) {
+		return
+	}
+
+	dbMessages, err := cfg.databaseQueries.ListMessages(r.Context(), database.ListMessagesParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve messages", err)
+		return
+	}
+
+	// Only the first page moves the read marker; paging back through history
+	// doesn't mean the newest messages were seen.
+	if offset == 0 {
+		err = cfg.databaseQueries.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ConversationID: conversationID,
+			UserID:         userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't mark conversation as read", err)
+			return
+		}
+	}
+
+	messages := []Message{}
+	for _, message := range dbMessages {
+		messages = append(messages, Message{
+			ID:             message.ID,
+			CreatedAt:      message.CreatedAt,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+			Body:           message.Body,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, messages)
+}
+
+func (cfg *apiConfig) createMessageHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	params := parameters{}
+	err = validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	v := validation.Validator{}
+	v.Required("body", params.Body)
+	v.MaxLength("body", params.Body, maxMessageLength)
+	if err := v.Err(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Invalid message", err)
+		return
+	}
+
+	if !cfg.requireConversationMember(w, r, conversationID, userID) {
+		return
+	}
+
+	// Nobody can message a conversation in which they've blocked, or been
+	// blocked by, another member.
+	blocked, err := cfg.databaseQueries.HasBlockInConversation(r.Context(), database.HasBlockInConversationParams{
+		UserID:         userID,
+		ConversationID: conversationID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check blocked users", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't send messages to this conversation", nil)
+		return
+	}
+
+	message, err := cfg.databaseQueries.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Body:           params.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+	err = cfg.databaseQueries.TouchConversation(r.Context(), conversationID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update conversation", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, Message{
+		ID:             message.ID,
+		CreatedAt:      message.CreatedAt,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+	})
+}
+
+// Hides a message from the caller's view of the conversation only; the other
+// members still see it.
+func (cfg *apiConfig) deleteMessageHandler(w http.ResponseWriter, r *http.Request) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+	messageID, err := uuid.Parse(r.PathValue("messageID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	deleted, err := cfg.databaseQueries.DeleteMessageForUser(r.Context(), database.DeleteMessageForUserParams{
+		ID:             messageID,
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete message", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Message not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireConversationMember writes a 404 and returns false when the user isn't
+// part of the conversation, so non-members can't probe which IDs exist.
+func (cfg *apiConfig) requireConversationMember(w http.ResponseWriter, r *http.Request, conversationID, userID uuid.UUID) bool {
+	isMember, err := cfg.databaseQueries.IsConversationMember(r.Context(), database.IsConversationMemberParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up conversation", err)
+		return false
+	}
+	if !isMember {
+		respondWithError(w, http.StatusNotFound, "Conversation not found", nil)
+		return false
+	}
+	return true
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"net/http"
-	"time"
 
 	"github.com/dandytron/chirpy.git/internal/auth"
-	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/metrics"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
 )
 
 func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -16,58 +19,54 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	type response struct {
 		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		CSRFToken    string `json:"csrf_token,omitempty"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err := decoder.Decode(&params)
+	cookies, err := cookieMode(r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not decode http request", nil)
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
-
-	retrievedUser, err := cfg.databaseQueries.FindUserByEmail(r.Context(), params.Email)
+	params := parameters{}
+	err = validation.DecodeJSON(w, r, &params)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	v := validation.Validator{}
+	v.Required("email", params.Email)
+	v.Required("password", params.Password)
+	if err := v.Err(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Email and password are required", err)
 		return
 	}
 
-	err = auth.CheckPasswordHash(params.Password, retrievedUser.HashedPassword)
-	if err != nil {
+	session, err := cfg.service.Login(r.Context(), params.Email, params.Password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		cfg.metrics.Logins.WithLabelValues(metrics.LoginFailed).Inc()
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
 		return
 	}
-
-	// Pass in time.Hour to represent "now" plus an hour's time.
-	accessToken, err := auth.MakeJWT(
-		retrievedUser.ID,
-		cfg.jwtsecret,
-		time.Hour,
-	)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
+	if errors.Is(err, service.ErrSuspended) {
+		cfg.metrics.Logins.WithLabelValues(metrics.LoginFailed).Inc()
+		respondWithError(w, http.StatusForbidden, "This account has been suspended", nil)
 		return
 	}
-
-	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create session", err)
 		return
 	}
 
-	_, err = cfg.databaseQueries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		UserID:    retrievedUser.ID,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 60),
-	})
+	accessToken, err := cfg.accessToken(r.Context(), session.User.ID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create session", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response{
+	retrievedUser := session.User
+	resp := response{
 		User: User{
 			ID:          retrievedUser.ID,
 			CreatedAt:   retrievedUser.CreatedAt,
@@ -76,6 +75,34 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 			IsChirpyRed: retrievedUser.IsChirpyRed.Bool,
 		},
 		Token:        accessToken,
-		RefreshToken: refreshToken,
-	})
+		RefreshToken: session.RefreshToken,
+	}
+	// A cookie session also needs its CSRF token for every request that
+	// changes something.
+	if cookies != cookiesNone {
+		cfg.setTokenCookie(w, auth.RefreshTokenCookie, session.RefreshToken, cfg.config.RefreshTokenTTL)
+		resp.RefreshToken = ""
+		resp.CSRFToken = cfg.csrfToken(session.RefreshToken)
+	}
+	if cookies == cookiesAll {
+		cfg.setTokenCookie(w, auth.AccessTokenCookie, accessToken, cfg.config.AccessTokenTTL)
+		resp.Token = ""
+	}
+	cfg.metrics.Logins.WithLabelValues(metrics.LoginSucceeded).Inc()
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// accessToken makes an access token for the user, carrying their current
+// roles and permissions, with the lifetime from the config.
+func (cfg *apiConfig) accessToken(ctx context.Context, userID uuid.UUID) (string, error) {
+	access, err := cfg.userAccess(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return auth.MakeJWT(
+		userID,
+		cfg.config.JWTSecret,
+		cfg.config.AccessTokenTTL,
+		access,
+	)
 }
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
+)
+
+// Moderator and admin actions. Every one of them is written to the
+// moderation_actions audit trail, alongside the report it resolves if any.
+const (
+	moderationActionHideChirp   = service.ActionHideChirp
+	moderationActionSuspendUser = service.ActionSuspendUser
+	moderationActionDismiss     = service.ActionDismiss
+	moderationActionAssign      = "assign"
+	moderationActionDeleteChirp = service.ActionDeleteChirp
+	moderationActionGrantRole   = "grant_role"
+	moderationActionRevokeRole  = "revoke_role"
+)
+
+var reportStatuses = []string{"open", "resolved", "dismissed"}
+
+type ModerationAction struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ModeratorID   *uuid.UUID `json:"moderator_id"`
+	ReportID      *uuid.UUID `json:"report_id"`
+	Action        string     `json:"action"`
+	TargetUserID  *uuid.UUID `json:"target_user_id"`
+	TargetChirpID *uuid.UUID `json:"target_chirp_id"`
+	Note          string     `json:"note"`
+}
+
+// Lists reports for the moderator queue, oldest first. Supports filtering by
+// status, reason, assignee_id, and unassigned=true.
+func (cfg *apiConfig) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	query := r.URL.Query()
+	params := database.ListReportsParams{
+		PageLimit:  limit,
+		PageOffset: offset,
+		Unassigned: query.Get("unassigned") == "true",
+	}
+	if status := query.Get("status"); status != "" {
+		if !slices.Contains(reportStatuses, status) {
+			respondWithError(w, http.StatusBadRequest, "Unknown report status", nil)
+			return
+		}
+		params.Status = sql.NullString{String: status, Valid: true}
+	}
+	if reason := query.Get("reason"); reason != "" {
+		if !slices.Contains(reportReasons, reason) {
+			respondWithError(w, http.StatusBadRequest, "Unknown report reason", nil)
+			return
+		}
+		params.Reason = sql.NullString{String: reason, Valid: true}
+	}
+	if assignee := query.Get("assignee_id"); assignee != "" {
+		assigneeID, err := uuid.Parse(assignee)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid assignee ID", err)
+			return
+		}
+		params.AssigneeID = uuid.NullUUID{UUID: assigneeID, Valid: true}
+	}
+
+	dbReports, err := cfg.databaseQueries.ListReports(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve reports", err)
+		return
+	}
+
+	reports := []Report{}
+	for _, report := range dbReports {
+		reports = append(reports, reportFromDatabase(report))
+	}
+	respondWithJSON(w, http.StatusOK, reports)
+}
+
+// Assigns a report to a moderator. Without an assignee_id in the body the
+// report is assigned to the caller.
+func (cfg *apiConfig) assignReportHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		AssigneeID uuid.UUID `json:"assignee_id"`
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	moderatorID := authenticatedUserFromContext(r.Context()).ID
+
+	params := parameters{}
+	err = validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.AssigneeID == uuid.Nil {
+		params.AssigneeID = moderatorID
+	}
+
+	canReview, err := cfg.databaseQueries.UserHasPermission(r.Context(), database.UserHasPermissionParams{
+		UserID:     params.AssigneeID,
+		Permission: auth.PermissionReportsReview,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up assignee", err)
+		return
+	}
+	if !canReview {
+		respondWithError(w, http.StatusBadRequest, "Reports can only be assigned to moderators", nil)
+		return
+	}
+
+	report, err := cfg.databaseQueries.AssignReport(r.Context(), database.AssignReportParams{
+		ID:         reportID,
+		AssigneeID: uuid.NullUUID{UUID: params.AssigneeID, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Report not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't assign report", err)
+		return
+	}
+
+	_, err = cfg.databaseQueries.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: moderatorID, Valid: true},
+		ReportID:     uuid.NullUUID{UUID: report.ID, Valid: true},
+		Action:       moderationActionAssign,
+		TargetUserID: uuid.NullUUID{UUID: params.AssigneeID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, reportFromDatabase(report))
+}
+
+// Takes a moderator action on an open report and closes it. hide_chirp and
+// suspend_user resolve the report; dismiss closes it with no further effect.
+func (cfg *apiConfig) reportActionHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	moderatorID := authenticatedUserFromContext(r.Context()).ID
+
+	params := parameters{}
+	err = validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	v := validation.Validator{}
+	v.OneOf("action", params.Action, moderationActionHideChirp, moderationActionSuspendUser, moderationActionDismiss)
+	if err := v.Err(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Unknown moderation action", err)
+		return
+	}
+
+	closed, err := cfg.service.ResolveReport(r.Context(), moderatorID, reportID, params.Action, params.Note)
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		respondWithError(w, http.StatusNotFound, "Report not found", nil)
+		return
+	case errors.Is(err, service.ErrReportClosed):
+		respondWithError(w, http.StatusConflict, "Report is already closed", nil)
+		return
+	case errors.Is(err, service.ErrNotChirpReport):
+		respondWithError(w, http.StatusBadRequest, "Report isn't about a chirp", nil)
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "Couldn't take moderation action", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, reportFromDatabase(closed))
+}
+
+// Returns the moderation audit trail, newest first.
+func (cfg *apiConfig) listModerationActionsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	dbActions, err := cfg.databaseQueries.ListModerationActions(r.Context(), database.ListModerationActionsParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve moderation actions", err)
+		return
+	}
+
+	actions := []ModerationAction{}
+	for _, action := range dbActions {
+		actions = append(actions, ModerationAction{
+			ID:            action.ID,
+			CreatedAt:     action.CreatedAt,
+			ModeratorID:   nullUUIDPointer(action.ModeratorID),
+			ReportID:      nullUUIDPointer(action.ReportID),
+			Action:        action.Action,
+			TargetUserID:  nullUUIDPointer(action.TargetUserID),
+			TargetChirpID: nullUUIDPointer(action.TargetChirpID),
+			Note:          action.Note,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, actions)
+}
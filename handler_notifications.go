@@ -0,0 +1,236 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
+)
+
+// NotificationGroup collapses notifications of the same type about the same
+// chirp into a single entry, so the client can render "5 people mentioned you
+// in a chirp".
+type NotificationGroup struct {
+	IDs         []uuid.UUID `json:"ids"`
+	Type        string      `json:"type"`
+	ChirpID     *uuid.UUID  `json:"chirp_id"`
+	ActorIDs    []uuid.UUID `json:"actor_ids"`
+	ActorCount  int         `json:"actor_count"`
+	UnreadCount int64       `json:"unread_count"`
+	Summary     string      `json:"summary"`
+	LatestAt    time.Time   `json:"latest_at"`
+}
+
+func (cfg *apiConfig) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		UnreadCount   int64               `json:"unread_count"`
+		Notifications []NotificationGroup `json:"notifications"`
+		Limit         int32               `json:"limit"`
+		Offset        int32               `json:"offset"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	groups, err := cfg.databaseQueries.ListNotificationGroups(r.Context(), database.ListNotificationGroupsParams{
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve notifications", err)
+		return
+	}
+	unread, err := cfg.databaseQueries.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count unread notifications", err)
+		return
+	}
+
+	notifications := []NotificationGroup{}
+	for _, group := range groups {
+		actorIDs := group.ActorIds
+		if actorIDs == nil {
+			actorIDs = []uuid.UUID{}
+		}
+		notifications = append(notifications, NotificationGroup{
+			IDs:         group.Ids,
+			Type:        group.Type,
+			ChirpID:     nullUUIDPointer(group.ChirpID),
+			ActorIDs:    actorIDs,
+			ActorCount:  len(actorIDs),
+			UnreadCount: group.UnreadCount,
+			Summary:     notificationSummary(group.Type, len(actorIDs)),
+			LatestAt:    group.LatestAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		UnreadCount:   unread,
+		Notifications: notifications,
+		Limit:         limit,
+		Offset:        offset,
+	})
+}
+
+func (cfg *apiConfig) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	params := parameters{}
+	err = validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if len(params.IDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "No notification IDs provided", nil)
+		return
+	}
+
+	// Only the recipient's own notifications are touched; unknown IDs are ignored.
+	_, err = cfg.databaseQueries.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+		UserID: userID,
+		Ids:    params.IDs,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notifications as read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	_, err = cfg.databaseQueries.MarkAllNotificationsRead(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mark notifications as read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Preferences are returned as a map of notification type to enabled flag.
+// Types the user never changed are enabled by default.
+func (cfg *apiConfig) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(r, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve notification preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+func (cfg *apiConfig) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	params := map[string]bool{}
+	err = validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	for notificationType := range params {
+		if !isNotificationType(notificationType) {
+			respondWithError(w, http.StatusBadRequest, "Unknown notification type: "+notificationType, nil)
+			return
+		}
+	}
+
+	for notificationType, enabled := range params {
+		_, err = cfg.databaseQueries.UpsertNotificationPreference(r.Context(), database.UpsertNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    notificationType,
+			Enabled: enabled,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't save notification preference", err)
+			return
+		}
+	}
+
+	prefs, err := cfg.notificationPreferences(r, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve notification preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+func (cfg *apiConfig) notificationPreferences(r *http.Request, userID uuid.UUID) (map[string]bool, error) {
+	stored, err := cfg.databaseQueries.ListNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	prefs := make(map[string]bool, len(notificationTypes))
+	for _, notificationType := range notificationTypes {
+		prefs[notificationType] = true
+	}
+	for _, pref := range stored {
+		if isNotificationType(pref.Type) {
+			prefs[pref.Type] = pref.Enabled
+		}
+	}
+	return prefs, nil
+}
@@ -2,17 +2,23 @@ package main
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/dandytron/chirpy.git/internal/auth"
 )
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	type response struct {
-		Token string `json:"token"`
+		Token string `json:"token,omitempty"`
 	}
 
-	refreshToken, err := auth.GetBearerToken(r.Header)
+	cookies, err := cookieMode(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	// The refresh token can come from the cookie set at login, as well as
+	// from the Authorization header.
+	refreshToken, err := auth.GetRefreshToken(r.Header)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Couldn't find token", err)
 		return
@@ -24,27 +30,33 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.jwtsecret,
-		time.Hour,
-	)
+	// Roles are reloaded on every refresh, so grants and revocations take
+	// effect within one access token lifetime.
+	accessToken, err := cfg.accessToken(r.Context(), user.ID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create access token", err)
 		return
 	}
 
+	if cookies == cookiesAll {
+		cfg.setTokenCookie(w, auth.AccessTokenCookie, accessToken, cfg.config.AccessTokenTTL)
+		accessToken = ""
+	}
 	respondWithJSON(w, http.StatusOK, response{
 		Token: accessToken,
 	})
 }
 
 func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
-	refreshToken, err := auth.GetBearerToken(r.Header)
+	refreshToken, err := auth.GetRefreshToken(r.Header)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not find token", err)
 		return
 	}
+	// Signing out of a cookie session clears the cookies too.
+	if r.Header.Get("Authorization") == "" {
+		cfg.clearTokenCookies(w)
+	}
 
 	_, err = cfg.databaseQueries.RevokeRefreshToken(r.Context(), refreshToken)
 	if err != nil {
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// Blocking hides both users' chirps from each other and stops the blocked user
+// from notifying or messaging the blocker. Muting quietly filters the muted
+// user out of the muter's own chirp feed and notifications.
+
+type Relationship struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.databaseQueries.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't block user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipRequest(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := cfg.databaseQueries.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unblock user", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "User isn't blocked", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.databaseQueries.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mute user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipRequest(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := cfg.databaseQueries.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unmute user", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "User isn't muted", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) listBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	blocks, err := cfg.databaseQueries.ListBlockedUsers(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve blocked users", err)
+		return
+	}
+
+	relationships := []Relationship{}
+	for _, block := range blocks {
+		relationships = append(relationships, Relationship{
+			UserID:    block.BlockedID,
+			CreatedAt: block.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, relationships)
+}
+
+func (cfg *apiConfig) listMutedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	mutes, err := cfg.databaseQueries.ListMutedUsers(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve muted users", err)
+		return
+	}
+
+	relationships := []Relationship{}
+	for _, mute := range mutes {
+		relationships = append(relationships, Relationship{
+			UserID:    mute.MutedID,
+			CreatedAt: mute.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, relationships)
+}
+
+// relationshipRequest authenticates the caller and resolves the {userID} path
+// value to an existing user other than the caller. It writes the error
+// response itself and returns false if anything is wrong.
+func (cfg *apiConfig) relationshipRequest(w http.ResponseWriter, r *http.Request) (userID, targetID uuid.UUID, ok bool) {
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err = auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't do that to yourself", nil)
+		return uuid.Nil, uuid.Nil, false
+	}
+	found, err := cfg.databaseQueries.CountUsersByIDs(r.Context(), []uuid.UUID{targetID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up user", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	if found == 0 {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, targetID, true
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
+)
+
+const maxReportDetailsLength = 1000
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "misinformation", "other"}
+
+type Report struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	ReporterID     uuid.UUID  `json:"reporter_id"`
+	ReportedUserID uuid.UUID  `json:"reported_user_id"`
+	ChirpID        *uuid.UUID `json:"chirp_id"`
+	Reason         string     `json:"reason"`
+	Details        string     `json:"details"`
+	Status         string     `json:"status"`
+	AssigneeID     *uuid.UUID `json:"assignee_id"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+}
+
+type reportParameters struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+func (cfg *apiConfig) reportChirpHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	userID, params, ok := cfg.decodeReportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Users can only report chirps they're able to see.
+	chirp, err := cfg.databaseQueries.RetrieveVisibleChirp(r.Context(), database.RetrieveVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if chirp.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't report your own chirp", nil)
+		return
+	}
+
+	report, err := cfg.databaseQueries.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID:     userID,
+		ReportedUserID: chirp.UserID,
+		ChirpID:        uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Reason:         params.Reason,
+		Details:        params.Details,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, reportFromDatabase(report))
+}
+
+func (cfg *apiConfig) reportUserHandler(w http.ResponseWriter, r *http.Request) {
+	reportedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	userID, params, ok := cfg.decodeReportRequest(w, r)
+	if !ok {
+		return
+	}
+	if reportedID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't report yourself", nil)
+		return
+	}
+
+	_, err = cfg.databaseQueries.GetUserByID(r.Context(), reportedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up user", err)
+		return
+	}
+
+	report, err := cfg.databaseQueries.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID:     userID,
+		ReportedUserID: reportedID,
+		Reason:         params.Reason,
+		Details:        params.Details,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, reportFromDatabase(report))
+}
+
+// decodeReportRequest authenticates the reporter and validates the report body.
+// It writes the error response itself and returns false if anything is wrong.
+func (cfg *apiConfig) decodeReportRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, reportParameters, bool) {
+	params := reportParameters{}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return uuid.Nil, params, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return uuid.Nil, params, false
+	}
+
+	err = validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return uuid.Nil, params, false
+	}
+	v := validation.Validator{}
+	v.OneOf("reason", params.Reason, reportReasons...)
+	v.MaxLength("details", params.Details, maxReportDetailsLength)
+	if err := v.Err(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Invalid report", err)
+		return uuid.Nil, params, false
+	}
+
+	return userID, params, true
+}
+
+func reportFromDatabase(report database.Report) Report {
+	var resolvedAt *time.Time
+	if report.ResolvedAt.Valid {
+		resolvedAt = &report.ResolvedAt.Time
+	}
+	return Report{
+		ID:             report.ID,
+		CreatedAt:      report.CreatedAt,
+		UpdatedAt:      report.UpdatedAt,
+		ReporterID:     report.ReporterID,
+		ReportedUserID: report.ReportedUserID,
+		ChirpID:        nullUUIDPointer(report.ChirpID),
+		Reason:         report.Reason,
+		Details:        report.Details,
+		Status:         report.Status,
+		AssigneeID:     nullUUIDPointer(report.AssigneeID),
+		ResolvedAt:     resolvedAt,
+	}
+}
+
+// nullUUIDPointer turns an optional database UUID into something that
+// marshals to either the ID or JSON null.
+func nullUUIDPointer(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
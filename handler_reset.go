@@ -4,13 +4,15 @@ import (
 	"net/http"
 )
 
-// apiConfig function that sets the file server hit counter back to 0
+// apiConfig function that sets the file server hit counter back to 0 and deletes
+// every user. Only available on the dev platform, and only to callers with the
+// system:reset permission.
 func (cfg *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
-	if cfg.platform != "dev" {
+	if cfg.config.Platform != "dev" {
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
-	cfg.fileserverHits.Store(int32(0))
+	cfg.metrics.FileserverHits.Reset()
 	err := cfg.databaseQueries.DeleteUsers(r.Context())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
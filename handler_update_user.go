@@ -1,29 +1,32 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/dandytron/chirpy.git/internal/auth"
-	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/validation"
 )
 
-func (cfg *apiConfig) updateCredentials(w http.ResponseWriter, r *http.Request) {
+// updateUserHandler changes the caller's email and/or password. Fields left
+// out of the request are not touched. Both changes need the current password;
+// a new email must be verified again, and a new password signs out every
+// other session.
+func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
 	}
 	type response struct {
 		User
-	}
-
-	// check for An access token in the header
-	// If the access token is malformed or missing, respond with a 401 status code.
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		respondWithError(w, http.StatusUnauthorized, "Access token not found or malformed", nil)
-		return
+		EmailVerified bool   `json:"email_verified"`
+		Token         string `json:"token,omitempty"`
+		RefreshToken  string `json:"refresh_token,omitempty"`
+		CSRFToken     string `json:"csrf_token,omitempty"`
 	}
 
 	token, err := auth.GetBearerToken(r.Header)
@@ -31,58 +34,95 @@ func (cfg *apiConfig) updateCredentials(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtsecret)
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Could not validate token", err)
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
 		return
 	}
 
-	// check for a new password and email in the request body
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err = validation.DecodeJSON(w, r, &params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not decode http request", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
 		return
 	}
-	if params.Email == "" || !strings.Contains(params.Email, "@") {
-		respondWithError(w, http.StatusInternalServerError, "Email either not provided or invalid", nil)
+	if params.Email == nil && params.Password == nil {
+		respondWithError(w, http.StatusBadRequest, "Nothing to update", nil)
 		return
 	}
-	if params.Password == "" {
-		respondWithError(w, http.StatusInternalServerError, "No password provided", nil)
-		return
+	v := validation.Validator{}
+	if params.Email != nil {
+		email := strings.TrimSpace(*params.Email)
+		v.Email("email", email)
+		params.Email = &email
 	}
-
-	// Hash the password
-	hashedPW, err := auth.HashPassword(params.Password)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not hash password: %v", err)
+	if params.Password != nil {
+		v.Required("password", *params.Password)
+	}
+	v.Required("current_password", params.CurrentPassword)
+	if err := v.Err(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Invalid update", err)
 		return
 	}
 
-	// update the hashed password and the email for the authenticated user in the database
-
-	updatedUser, err := cfg.databaseQueries.UpdateUser(r.Context(), database.UpdateUserParams{
-		ID:             userID,
-		Email:          params.Email,
-		HashedPassword: hashedPW,
+	updated, err := cfg.service.UpdateUser(r.Context(), userID, service.UserUpdate{
+		Email:           params.Email,
+		Password:        params.Password,
+		CurrentPassword: params.CurrentPassword,
 	})
-	if err != nil {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	case errors.Is(err, service.ErrInvalidCredentials):
+		respondWithError(w, http.StatusUnauthorized, "Current password is incorrect", nil)
+		return
+	case errors.Is(err, service.ErrEmailTaken):
+		respondWithError(w, http.StatusConflict, "Email is already in use", nil)
+		return
+	case err != nil:
 		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
 		return
 	}
+	user := updated.User
+
+	if updated.VerificationToken != "" {
+		err = sendEmailVerification(r.Context(), cfg.mailer, user.Email, updated.VerificationToken)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Couldn't send verification email", "user_id", user.ID, "error", err)
+		}
+	}
 
-	// Respond with a 200 if everything is successful and the newly
-	// updated User resource (omitting the password of course).
+	// Every other session was revoked along with the old password; hand the
+	// caller a fresh access token to go with their new refresh token. A
+	// cookie session gets them the way it got them at login: in cookies,
+	// with a CSRF token for the new session instead.
+	resp := response{}
+	if updated.RefreshToken != "" {
+		resp.Token, err = cfg.accessToken(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create session", err)
+			return
+		}
+		resp.RefreshToken = updated.RefreshToken
+		if _, ok := auth.GetCookie(r.Header, auth.RefreshTokenCookie); ok {
+			cfg.setTokenCookie(w, auth.RefreshTokenCookie, resp.RefreshToken, cfg.config.RefreshTokenTTL)
+			resp.CSRFToken = cfg.csrfToken(resp.RefreshToken)
+			resp.RefreshToken = ""
+		}
+		if r.Header.Get("Authorization") == "" {
+			cfg.setTokenCookie(w, auth.AccessTokenCookie, resp.Token, cfg.config.AccessTokenTTL)
+			resp.Token = ""
+		}
+	}
 
-	respondWithJSON(w, http.StatusOK, response{
-		User{
-			ID:          updatedUser.ID,
-			CreatedAt:   updatedUser.CreatedAt,
-			UpdatedAt:   updatedUser.UpdatedAt,
-			Email:       updatedUser.Email,
-			IsChirpyRed: updatedUser.IsChirpyRed.Bool,
-		},
-	})
+	resp.User = User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed.Bool,
+	}
+	resp.EmailVerified = user.EmailVerifiedAt.Valid
+	respondWithJSON(w, http.StatusOK, resp)
 }
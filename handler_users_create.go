@@ -1,52 +1,50 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"time"
 
-	"github.com/dandytron/chirpy.git/internal/auth"
-	"github.com/dandytron/chirpy.git/internal/database"
-	"github.com/google/uuid"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/validation"
 )
 
 func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse JSON request
 	type parameters struct {
 		Email    string `json:"email"`
-		Password string
+		Password string `json:"password"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := validation.DecodeJSON(w, r, &params)
 	if err != nil {
-		log.Println("createUserHandler: error decoding:", err)
-		respondWithError(w, http.StatusInternalServerError, "Could not decode http request", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
 		return
 	}
-
-	// Hash password
-	hashedPW, err := auth.HashPassword(params.Password)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not hash password: %v", err)
+	v := validation.Validator{}
+	v.Required("email", params.Email)
+	v.Email("email", params.Email)
+	v.Required("password", params.Password)
+	if err := v.Err(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Invalid user", err)
 		return
 	}
 
-	// Call SQLC to create user
-	dbUser, err := cfg.databaseQueries.CreateUser(r.Context(), database.CreateUserParams{
-		ID:             uuid.New(),
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
-		HashedPassword: hashedPW,
-		Email:          params.Email,
-	})
+	signup, err := cfg.service.CreateUser(r.Context(), params.Email, params.Password)
+	if errors.Is(err, service.ErrEmailTaken) {
+		respondWithError(w, http.StatusConflict, "Email is already in use", nil)
+		return
+	}
 	if err != nil {
-		log.Println("createUserHandler: error creating user:", err)
 		respondWithError(w, http.StatusInternalServerError, "Could not create user", err)
 		return
 	}
+	dbUser := signup.User
+	err = sendEmailVerification(r.Context(), cfg.mailer, dbUser.Email, signup.VerificationToken)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Couldn't send verification email", "user_id", dbUser.ID, "error", err)
+	}
 
 	// Convert to response model, use respondWithJson function to send response
 	user := User{
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/validation"
+)
+
+// deleteAccountHandler soft-deletes the caller's account after re-checking
+// their password. The account disappears immediately but can be restored by
+// logging in again until the grace period runs out.
+func (cfg *apiConfig) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+	type response struct {
+		PurgeAfter time.Time `json:"purge_after"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	params := parameters{}
+	err = validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	v := validation.Validator{}
+	v.Required("password", params.Password)
+	if err := v.Err(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Password is required to delete an account", err)
+		return
+	}
+
+	purgeAfter, err := cfg.service.DeleteAccount(r.Context(), userID, params.Password)
+	if errors.Is(err, service.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		PurgeAfter: purgeAfter,
+	})
+}
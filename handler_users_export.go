@@ -0,0 +1,96 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+)
+
+// exportAccountHandler sends the caller a ZIP archive holding their profile
+// and every chirp they have written, each as a JSON document.
+func (cfg *apiConfig) exportAccountHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	user, err := cfg.databaseQueries.GetUserByID(r.Context(), userID)
+	if err != nil || user.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	dbChirps, err := cfg.databaseQueries.ListChirpsByAuthor(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+
+	chirps := make([]Chirp, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		chirps = append(chirps, Chirp{
+			ID:        dbChirp.ID,
+			CreatedAt: dbChirp.CreatedAt,
+			UpdatedAt: dbChirp.UpdatedAt,
+			Body:      dbChirp.Body,
+			UserID:    dbChirp.UserID,
+		})
+	}
+	profile := User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed.Bool,
+	}
+
+	// Build the whole archive before writing anything, so a failure can
+	// still be reported as a JSON error.
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"chirps.json", chirps},
+	}
+	for _, file := range files {
+		err = writeZipJSON(archive, file.name, file.data)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't build export", err)
+			return
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't build export", err)
+		return
+	}
+
+	filename := fmt.Sprintf("chirpy-export-%s.zip", time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func writeZipJSON(archive *zip.Writer, name string, data interface{}) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
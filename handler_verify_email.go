@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/validation"
+)
+
+func (cfg *apiConfig) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	params := parameters{}
+	err := validation.DecodeJSON(w, r, &params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	v := validation.Validator{}
+	v.Required("token", params.Token)
+	if err := v.Err(); err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, "Verification token is required", err)
+		return
+	}
+
+	err = cfg.service.VerifyEmail(r.Context(), params.Token)
+	if errors.Is(err, service.ErrInvalidToken) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired verification token", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resendVerificationHandler mails the caller a new verification token for
+// their current email. It is rate limited with the other auth endpoints.
+func (cfg *apiConfig) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	signup, err := cfg.service.ResendEmailVerification(r.Context(), userID)
+	if errors.Is(err, service.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+	if errors.Is(err, service.ErrAlreadyVerified) {
+		respondWithError(w, http.StatusConflict, "Email is already verified", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't issue verification token", err)
+		return
+	}
+	// Sending the email is the whole point here, so unlike at signup a
+	// failure is the caller's to know about.
+	err = sendEmailVerification(r.Context(), cfg.mailer, signup.User.Email, signup.VerificationToken)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
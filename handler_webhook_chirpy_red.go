@@ -1,12 +1,11 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/validation"
 	"github.com/google/uuid"
 )
 
@@ -32,32 +31,30 @@ func (cfg *apiConfig) chirpyRedHandler(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Could not fetch API key from JSON header", err)
 		return
 	}
-	fmt.Println("Received API key:", webhookApiKey)
-	fmt.Println("Expected API key:", cfg.polkaAPIKey)
-	fmt.Println("Are they equal?", webhookApiKey == cfg.polkaAPIKey)
-	if webhookApiKey != cfg.polkaAPIKey {
+	if webhookApiKey != cfg.config.PolkaKey {
 		respondWithError(w, http.StatusUnauthorized, "API key does not match our records", err)
 		return
 	}
 
 	// Grab the webhook params from the request body
-	decoder := json.NewDecoder(r.Body)
 	webhook := PolkaWebhook{}
-	err = decoder.Decode(&webhook)
+	err = validation.DecodeJSONAllowUnknown(w, r, &webhook)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
 		return
 	}
 
 	// If the event is anything other than user.upgraded, the endpoint should immediately
 	// respond with a 204 status code - we don't care about any other events.
 	if webhook.Event != "user.upgraded" {
+		cfg.metrics.WebhookEvents.WithLabelValues("other").Inc()
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	// If the event is user.upgraded, then it should update the user in the database
 	// and mark that they are a Chirpy Red member.
+	cfg.metrics.WebhookEvents.WithLabelValues(webhook.Event).Inc()
 
 	uuidUserID, err := uuid.Parse(webhook.Data.UserID)
 	if err != nil {
@@ -70,6 +67,7 @@ func (cfg *apiConfig) chirpyRedHandler(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
 		return
 	}
+	cfg.notify(r.Context(), uuidUserID, uuid.Nil, notificationTypeAccount, uuid.Nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
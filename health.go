@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dandytron/chirpy.git/sql/schema"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+const (
+	healthStatusOK       = "ok"
+	healthStatusFail     = "fail"
+	healthStatusDegraded = "degraded"
+)
+
+// HealthCheck is the result of probing one dependency.
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// handlerLiveness reports that the process is up and able to serve HTTP. It
+// deliberately checks nothing else, so a database outage doesn't get the
+// process restarted.
+func handlerLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// handlerReadiness reports whether the server should receive traffic. It
+// responds 503 when a critical check fails, and as soon as a shutdown begins
+// so load balancers stop routing here before connections are closed.
+// Non-critical failures mark the server degraded but keep it in rotation.
+func (cfg *apiConfig) handlerReadiness(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Status string                 `json:"status"`
+		Checks map[string]HealthCheck `json:"checks"`
+	}
+
+	if cfg.shuttingDown.Load() {
+		respondWithJSON(w, http.StatusServiceUnavailable, response{
+			Status: "shutting_down",
+			Checks: map[string]HealthCheck{},
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]HealthCheck{}
+	// The in-memory store has no database to ping or migrate.
+	if cfg.db != nil {
+		checks["database"] = runHealthCheck(true, func() error { return cfg.db.PingContext(ctx) })
+		checks["migrations"] = runHealthCheck(true, func() error { return cfg.checkSchemaVersion(ctx) })
+	}
+	for name, check := range cfg.workers.checks() {
+		checks["worker:"+name] = check
+	}
+
+	resp := response{Status: healthStatusOK, Checks: checks}
+	code := http.StatusOK
+	for _, check := range checks {
+		if check.Status == healthStatusOK {
+			continue
+		}
+		if check.Critical {
+			resp.Status = healthStatusFail
+			code = http.StatusServiceUnavailable
+			break
+		}
+		resp.Status = healthStatusDegraded
+	}
+	respondWithJSON(w, code, resp)
+}
+
+func runHealthCheck(critical bool, check func() error) HealthCheck {
+	start := time.Now()
+	err := check()
+	result := HealthCheck{
+		Status:    healthStatusOK,
+		Critical:  critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = healthStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkSchemaVersion makes sure the database has every migration this
+// binary was built with. A newer schema is fine: during a rolling deploy the
+// first new replica migrates while the old ones are still serving, and they
+// shouldn't all drop out of rotation for it.
+func (cfg *apiConfig) checkSchemaVersion(ctx context.Context) error {
+	want, err := schema.LatestVersion()
+	if err != nil {
+		return err
+	}
+	var got int64
+	err = cfg.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied").Scan(&got)
+	if err != nil {
+		return err
+	}
+	if got < want {
+		return fmt.Errorf("database is at migration %d, expected at least %d", got, want)
+	}
+	return nil
+}
+
+// workerHealth tracks when each background worker last ran and whether it
+// succeeded. A worker that misses two runs in a row is reported as failing.
+type workerHealth struct {
+	mu      sync.Mutex
+	workers map[string]*workerState
+}
+
+type workerState struct {
+	interval time.Duration
+	lastRun  time.Time
+	lastErr  error
+}
+
+func newWorkerHealth() *workerHealth {
+	return &workerHealth{workers: map[string]*workerState{}}
+}
+
+// register adds a worker that is expected to run every interval.
+func (h *workerHealth) register(name string, interval time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.workers[name] = &workerState{interval: interval, lastRun: time.Now()}
+}
+
+// record notes that a worker has just finished a run.
+func (h *workerHealth) record(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	state, ok := h.workers[name]
+	if !ok {
+		return
+	}
+	state.lastRun = time.Now()
+	state.lastErr = err
+}
+
+func (h *workerHealth) checks() map[string]HealthCheck {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	checks := make(map[string]HealthCheck, len(h.workers))
+	for name, state := range h.workers {
+		check := HealthCheck{Status: healthStatusOK}
+		switch {
+		case time.Since(state.lastRun) > 2*state.interval:
+			check.Status = healthStatusFail
+			check.Error = "last ran " + state.lastRun.UTC().Format(time.RFC3339)
+		case state.lastErr != nil:
+			check.Status = healthStatusFail
+			check.Error = state.lastErr.Error()
+		}
+		checks[name] = check
+	}
+	return checks
+}
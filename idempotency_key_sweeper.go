@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	idempotencyKeySweeperWorker = "idempotency_key_sweeper"
+	idempotencyKeySweepInterval = time.Hour
+)
+
+// runIdempotencyKeySweeper deletes idempotency keys that have expired. They
+// are already ignored once expired; this just stops the table growing.
+func (cfg *apiConfig) runIdempotencyKeySweeper(ctx context.Context, interval time.Duration) {
+	cfg.workers.register(idempotencyKeySweeperWorker, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.sweepIdempotencyKeys(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) sweepIdempotencyKeys(ctx context.Context) {
+	deleted, err := cfg.databaseQueries.DeleteExpiredIdempotencyKeys(ctx, time.Now().UTC())
+	cfg.workers.record(idempotencyKeySweeperWorker, err)
+	if err != nil {
+		slog.ErrorContext(ctx, "Couldn't sweep idempotency keys", "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "Deleted expired idempotency keys", "count", deleted)
+	}
+}
@@ -21,9 +21,43 @@ const (
 	TokenTypeAccess TokenType = "chirpy-access"
 )
 
+// Permissions that can be granted to a role. Roles and the permissions
+// they carry live in the database; these are the names the server checks.
+const (
+	PermissionMetricsRead     = "metrics:read"
+	PermissionSystemReset     = "system:reset"
+	PermissionRolesManage     = "roles:manage"
+	PermissionReportsReview   = "reports:review"
+	PermissionChirpsDeleteAny = "chirps:delete_any"
+)
+
 // ErrNoAuthHeaderIncluded -
 var ErrNoAuthHeaderIncluded = errors.New("no auth header included in request")
 
+// Access holds the roles a user had when their access token was issued and
+// the permissions those roles grant.
+type Access struct {
+	Roles       []string
+	Permissions []string
+}
+
+// HasPermission reports whether the permission was granted by any role.
+func (a Access) HasPermission(permission string) bool {
+	for _, p := range a.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// accessClaims are the claims carried by Chirpy access tokens.
+type accessClaims struct {
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	jwt.RegisteredClaims
+}
+
 // HashPassword
 func HashPassword(password string) (string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -39,18 +73,23 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-// Make a JSON Web Token
+// Make a JSON Web Token, embedding the user's roles and permissions as claims
 func MakeJWT(
 	userID uuid.UUID,
 	tokenSecret string,
 	expiresIn time.Duration,
+	access Access,
 ) (string, error) {
 	signingKey := []byte(tokenSecret)
-	newJWT := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
+	newJWT := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims{
+		Roles:       access.Roles,
+		Permissions: access.Permissions,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
 	})
 
 	return newJWT.SignedString(signingKey)
@@ -58,40 +97,72 @@ func MakeJWT(
 
 // Validate an incoming JSON Web Token
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
+	id, _, err := ValidateJWTAccess(tokenString, tokenSecret)
+	return id, err
+}
+
+// ValidateJWTAccess validates an incoming JSON Web Token like ValidateJWT and
+// also returns the roles and permissions embedded in it
+func ValidateJWTAccess(tokenString, tokenSecret string) (uuid.UUID, Access, error) {
+	claimsStruct := accessClaims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claimsStruct,
 		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
 	)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, Access{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, Access{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, Access{}, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return uuid.Nil, Access{}, errors.New("invalid issuer")
 	}
 
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+		return uuid.Nil, Access{}, fmt.Errorf("invalid user ID: %w", err)
 	}
-	return id, nil
+	return id, Access{
+		Roles:       claimsStruct.Roles,
+		Permissions: claimsStruct.Permissions,
+	}, nil
 }
 
-// GetBearerToken
+// Cookies that carry tokens for browser sessions, so pages don't have to
+// keep them where scripts can read them.
+const (
+	AccessTokenCookie  = "chirpy_access"
+	RefreshTokenCookie = "chirpy_refresh"
+)
+
+// GetBearerToken returns the access token from the Authorization header, or
+// from the access token cookie if there's no header. A malformed header is
+// an error rather than a reason to look at the cookie.
 func GetBearerToken(headers http.Header) (string, error) {
+	return tokenFromHeaderOrCookie(headers, AccessTokenCookie)
+}
+
+// GetRefreshToken returns the refresh token from the Authorization header,
+// or from the refresh token cookie if there's no header.
+func GetRefreshToken(headers http.Header) (string, error) {
+	return tokenFromHeaderOrCookie(headers, RefreshTokenCookie)
+}
+
+func tokenFromHeaderOrCookie(headers http.Header, cookie string) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
+		if token, ok := GetCookie(headers, cookie); ok {
+			return token, nil
+		}
 		return "", ErrNoAuthHeaderIncluded
 	}
 
@@ -106,6 +177,22 @@ func GetBearerToken(headers http.Header) (string, error) {
 
 }
 
+// GetCookie returns the value of the named cookie from a request's headers.
+func GetCookie(headers http.Header, name string) (string, bool) {
+	for _, line := range headers.Values("Cookie") {
+		cookies, err := http.ParseCookie(line)
+		if err != nil {
+			continue
+		}
+		for _, c := range cookies {
+			if c.Name == name && c.Value != "" {
+				return c.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
 // This generates a random 256-bit (32-byte) hex-encoded string.
 func MakeRefreshToken() (string, error) {
 	randomData := make([]byte, 32)
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"net/http"
 	"testing"
 	"time"
 
@@ -70,7 +71,7 @@ func TestCheckPasswordHash(t *testing.T) {
 func TestValidateJWT(t *testing.T) {
 	//Create a user id and a token for testing
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
+	validToken, _ := MakeJWT(userID, "secret", time.Hour, Access{})
 
 	tests := []struct {
 		name        string
@@ -115,3 +116,96 @@ func TestValidateJWT(t *testing.T) {
 	}
 
 }
+
+func TestValidateJWTAccess(t *testing.T) {
+	userID := uuid.New()
+	access := Access{
+		Roles:       []string{"moderator"},
+		Permissions: []string{PermissionChirpsDeleteAny, PermissionReportsReview},
+	}
+	token, _ := MakeJWT(userID, "secret", time.Hour, access)
+
+	gotUserID, gotAccess, err := ValidateJWTAccess(token, "secret")
+	if err != nil {
+		t.Fatalf("ValidateJWTAccess() error = %v", err)
+	}
+	if gotUserID != userID {
+		t.Errorf("ValidateJWTAccess() gotUserID = %v, want %v", gotUserID, userID)
+	}
+
+	tests := []struct {
+		permission string
+		want       bool
+	}{
+		{permission: PermissionReportsReview, want: true},
+		{permission: PermissionChirpsDeleteAny, want: true},
+		{permission: PermissionRolesManage, want: false},
+		{permission: PermissionMetricsRead, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.permission, func(t *testing.T) {
+			if got := gotAccess.HasPermission(tt.permission); got != tt.want {
+				t.Errorf("HasPermission(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBearerToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   http.Header
+		wantToken string
+		wantErr   bool
+	}{
+		{
+			name:      "Authorization header",
+			headers:   http.Header{"Authorization": {"Bearer header-token"}},
+			wantToken: "header-token",
+		},
+		{
+			name: "Header wins over cookie",
+			headers: http.Header{
+				"Authorization": {"Bearer header-token"},
+				"Cookie":        {AccessTokenCookie + "=cookie-token"},
+			},
+			wantToken: "header-token",
+		},
+		{
+			name:      "Cookie when there's no header",
+			headers:   http.Header{"Cookie": {"other=1; " + AccessTokenCookie + "=cookie-token"}},
+			wantToken: "cookie-token",
+		},
+		{
+			name: "Malformed header doesn't fall back",
+			headers: http.Header{
+				"Authorization": {"ApiKey key"},
+				"Cookie":        {AccessTokenCookie + "=cookie-token"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Only the refresh cookie",
+			headers: http.Header{"Cookie": {RefreshTokenCookie + "=refresh-token"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := GetBearerToken(tt.headers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetBearerToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if token != tt.wantToken {
+				t.Errorf("GetBearerToken() = %q, want %q", token, tt.wantToken)
+			}
+		})
+	}
+
+	token, err := GetRefreshToken(http.Header{"Cookie": {RefreshTokenCookie + "=refresh-token"}})
+	if err != nil || token != "refresh-token" {
+		t.Errorf("GetRefreshToken() = %q, %v; want the refresh cookie", token, err)
+	}
+}
@@ -0,0 +1,23 @@
+// Package cache keeps hot database reads out of Postgres. A Store wraps the
+// queries the service layer runs on and answers chirp and user lookups from
+// a Cache, loading misses once however many requests ask at the same time,
+// and dropping entries whenever a query changes the rows behind them.
+//
+// A Cache is either an LRU in this process's memory or a Redis server, which
+// lets several instances share entries and see each other's invalidations.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores values by key for up to a TTL.
+type Cache interface {
+	// Get returns the value stored under key, and whether there was one.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Clear drops every entry.
+	Clear(ctx context.Context) error
+}
@@ -0,0 +1,429 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/memstore"
+	"github.com/google/uuid"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("least recently used entry wasn't evicted")
+	}
+	if v, ok, _ := c.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Errorf("Get(a) = %q, %v; want the recently used entry kept", v, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Error("expired entry was returned")
+	}
+	c.Delete(ctx, "c")
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d after expiry and delete, want 0", c.Len())
+	}
+}
+
+// countingSource counts the lookups that reach the database.
+type countingSource struct {
+	*memstore.Store
+	chirpLoads atomic.Int32
+	userLoads  atomic.Int32
+	release    chan struct{}
+}
+
+func (s *countingSource) RetrieveSingleChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.chirpLoads.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	// Like a database, give up on a cancelled load.
+	if err := ctx.Err(); err != nil {
+		return database.Chirp{}, err
+	}
+	return s.Store.RetrieveSingleChirp(ctx, id)
+}
+
+func (s *countingSource) FindUserByEmail(ctx context.Context, email string) (database.User, error) {
+	s.userLoads.Add(1)
+	return s.Store.FindUserByEmail(ctx, email)
+}
+
+func (s *countingSource) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.userLoads.Add(1)
+	return s.Store.GetUserByID(ctx, id)
+}
+
+func newTestStore(t *testing.T) (*Store, *countingSource, database.User, database.Chirp) {
+	t.Helper()
+	ctx := context.Background()
+	src := &countingSource{Store: memstore.New()}
+	user, err := src.CreateUser(ctx, database.CreateUserParams{Email: "user@example.com", HashedPassword: "$2a$10$hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirp, err := src.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(src, NewLRU(100), time.Minute), src, user, chirp
+}
+
+func TestStoreChirps(t *testing.T) {
+	ctx := context.Background()
+	s, src, _, chirp := newTestStore(t)
+
+	for range 3 {
+		got, err := s.RetrieveSingleChirp(ctx, chirp.ID)
+		if err != nil || got.Body != "hello" {
+			t.Fatalf("RetrieveSingleChirp() = %+v, %v", got, err)
+		}
+	}
+	if n := src.chirpLoads.Load(); n != 1 {
+		t.Errorf("chirp loaded %d times, want 1", n)
+	}
+
+	err := s.InTx(ctx, func(q database.Querier) error {
+		return q.HideChirp(ctx, chirp.ID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.RetrieveSingleChirp(ctx, chirp.ID)
+	if err != nil || !got.HiddenAt.Valid {
+		t.Errorf("RetrieveSingleChirp() after hiding = %+v, %v; want the hidden chirp", got, err)
+	}
+
+	err = s.DeleteChirps(ctx, chirp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.RetrieveSingleChirp(ctx, chirp.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RetrieveSingleChirp() after deleting error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestStoreSharesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	s, src, _, chirp := newTestStore(t)
+	src.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.RetrieveSingleChirp(ctx, chirp.ID); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// Let the first load finish once the others have had a chance to pile
+	// up behind it.
+	time.Sleep(50 * time.Millisecond)
+	close(src.release)
+	wg.Wait()
+	if n := src.chirpLoads.Load(); n != 1 {
+		t.Errorf("chirp loaded %d times by concurrent misses, want 1", n)
+	}
+}
+
+func TestStoreSharedLoadOutlivesCaller(t *testing.T) {
+	s, src, _, chirp := newTestStore(t)
+	src.release = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := s.RetrieveSingleChirp(ctx, chirp.ID)
+		first <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	second := make(chan error, 1)
+	go func() {
+		_, err := s.RetrieveSingleChirp(context.Background(), chirp.ID)
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// The caller that started the load gives up without waiting for it,
+	// and without failing the other caller.
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller error = %v, want context.Canceled", err)
+	}
+	close(src.release)
+	if err := <-second; err != nil {
+		t.Errorf("waiting caller error = %v, want the chirp", err)
+	}
+	if n := src.chirpLoads.Load(); n != 1 {
+		t.Errorf("chirp loaded %d times, want 1", n)
+	}
+}
+
+func TestStoreUsers(t *testing.T) {
+	ctx := context.Background()
+	s, src, user, _ := newTestStore(t)
+
+	s.FindUserByEmail(ctx, user.Email)
+	s.FindUserByEmail(ctx, user.Email)
+	s.GetUserByID(ctx, user.ID)
+	if n := src.userLoads.Load(); n != 1 {
+		t.Errorf("user loaded %d times, want 1", n)
+	}
+
+	// Password hashes stay out of the cache, and out of users that come
+	// from it.
+	cached, _, _ := s.cache.Get(ctx, userKey(user.ID))
+	if len(cached) == 0 || strings.Contains(string(cached), user.HashedPassword) {
+		t.Errorf("cached user = %s, want one without the password hash", cached)
+	}
+	for _, get := range []func() (database.User, error){
+		func() (database.User, error) { return s.GetUserByID(ctx, user.ID) },
+		func() (database.User, error) { return s.FindUserByEmail(ctx, user.Email) },
+	} {
+		if got, err := get(); err != nil || got.HashedPassword != "" {
+			t.Errorf("user from the cache = %+v, %v; want no password hash", got, err)
+		}
+	}
+	if hash, err := s.GetUserPasswordHash(ctx, user.ID); err != nil || hash != user.HashedPassword {
+		t.Errorf("GetUserPasswordHash() = %q, %v; want %q", hash, err, user.HashedPassword)
+	}
+
+	_, err := s.UpdateUserEmail(ctx, database.UpdateUserEmailParams{ID: user.ID, Email: "new@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.FindUserByEmail(ctx, user.Email)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("FindUserByEmail(old email) error = %v, want sql.ErrNoRows", err)
+	}
+	got, err := s.FindUserByEmail(ctx, "new@example.com")
+	if err != nil || got.ID != user.ID {
+		t.Errorf("FindUserByEmail(new email) = %+v, %v", got, err)
+	}
+
+	err = s.SuspendUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = s.GetUserByID(ctx, user.ID)
+	if !got.SuspendedAt.Valid {
+		t.Error("GetUserByID() returned the user from before they were suspended")
+	}
+
+	err = s.DeleteUsers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetUserByID(ctx, user.ID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByID() after DeleteUsers error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	addr := startFakeRedis(t)
+	r, err := NewRedis("redis://:secret@" + addr + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, ok, err := r.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v; want a miss", ok, err)
+	}
+	r.Set(ctx, "a", []byte("value\r\nwith a line break"), time.Minute)
+	r.Set(ctx, "b", []byte("2"), time.Minute)
+	if v, ok, err := r.Get(ctx, "a"); !ok || err != nil || string(v) != "value\r\nwith a line break" {
+		t.Errorf("Get(a) = %q, %v, %v", v, ok, err)
+	}
+	r.Delete(ctx, "a")
+	if _, ok, _ := r.Get(ctx, "a"); ok {
+		t.Error("deleted key is still there")
+	}
+	if err := r.Clear(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := r.Get(ctx, "b"); ok {
+		t.Error("Clear() left a key behind")
+	}
+
+	for _, bad := range []string{"localhost:6379", "http://host", "redis://host/db"} {
+		if _, err := NewRedis(bad); err == nil {
+			t.Errorf("NewRedis(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRedisErrorInArray(t *testing.T) {
+	// An error inside an array is reported once the whole array is read,
+	// leaving the next reply where the next command expects it.
+	c := &redisConn{br: bufio.NewReader(strings.NewReader("*3\r\n$1\r\na\r\n-ERR nope\r\n:3\r\n+OK\r\n"))}
+	if _, err := c.readReply(); err == nil || err.Error() != "redis: ERR nope" {
+		t.Errorf("readReply() error = %v, want the error reply", err)
+	}
+	if reply, err := c.readReply(); err != nil || fmt.Sprintf("%s", reply) != "OK" {
+		t.Errorf("next readReply() = %v, %v; want OK", reply, err)
+	}
+}
+
+func TestRedisConnectionLimit(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewRedis("redis://:secret@" + startFakeRedis(t) + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.open = make(chan struct{}, 1)
+
+	held, err := r.conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.conn(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("conn() over the limit error = %v, want it to wait until the deadline", err)
+	}
+
+	// A command waits for the held connection to come free, then reuses it.
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := r.Get(ctx, "a")
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	r.idle <- held
+	if err := <-done; err != nil {
+		t.Errorf("Get() after the connection came free error = %v", err)
+	}
+}
+
+// startFakeRedis serves the handful of commands the Redis cache uses,
+// requiring AUTH and SELECT first.
+func startFakeRedis(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	data := map[string]string{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				authed, selected := false, false
+				for {
+					args, err := readCommand(br)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					var reply string
+					switch cmd := strings.ToUpper(args[0]); {
+					case cmd == "AUTH":
+						authed = args[1] == "secret"
+						reply = "+OK\r\n"
+					case !authed:
+						reply = "-NOAUTH Authentication required.\r\n"
+					case cmd == "SELECT":
+						selected = args[1] == "2"
+						reply = "+OK\r\n"
+					case !selected:
+						reply = "-ERR wrong database\r\n"
+					case cmd == "GET":
+						v, ok := data[args[1]]
+						reply = "$-1\r\n"
+						if ok {
+							reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+						}
+					case cmd == "SET":
+						data[args[1]] = args[2]
+						reply = "+OK\r\n"
+					case cmd == "DEL":
+						for _, k := range args[1:] {
+							delete(data, k)
+						}
+						reply = ":1\r\n"
+					case cmd == "SCAN":
+						prefix := strings.TrimSuffix(args[3], "*")
+						var keys []string
+						for k := range data {
+							if strings.HasPrefix(k, prefix) {
+								keys = append(keys, fmt.Sprintf("$%d\r\n%s\r\n", len(k), k))
+							}
+						}
+						reply = fmt.Sprintf("*2\r\n$1\r\n0\r\n*%d\r\n%s", len(keys), strings.Join(keys, ""))
+					default:
+						reply = "-ERR unknown command\r\n"
+					}
+					mu.Unlock()
+					io.WriteString(conn, reply)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func readCommand(br *bufio.Reader) ([]string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Every key is prefixed so Clear only removes Chirpy's entries from a
+	// server that may be shared.
+	redisKeyPrefix = "chirpy:"
+	redisTimeout   = time.Second
+	redisMaxIdle   = 8
+	redisMaxOpen   = 64
+)
+
+// Redis is a Cache on a server speaking the Redis protocol, so every
+// instance shares one cache. It only needs GET, SET, DEL and SCAN, which
+// Redis-compatible servers such as Valkey, KeyDB and Dragonfly all support.
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	idle     chan *redisConn
+	// open holds a slot for every open connection, idle or in use, so a
+	// burst of requests waits for a connection rather than opening one
+	// each.
+	open chan struct{}
+}
+
+// NewRedis returns a Redis cache for a URL of the form
+// redis://[:password@]host:port[/db]. Connections are made as needed, up to
+// a limit.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("redis URL %q must look like redis://host:port", rawURL)
+	}
+	r := &Redis{
+		addr: u.Host,
+		idle: make(chan *redisConn, redisMaxIdle),
+		open: make(chan struct{}, redisMaxOpen),
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	r.password, _ = u.User.Password()
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		r.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("redis URL %q has an invalid database number", rawURL)
+		}
+	}
+	return r, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", redisKeyPrefix+key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis GET: unexpected reply %v", reply)
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.do(ctx, "SET", redisKeyPrefix+key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := []string{"DEL"}
+	for _, key := range keys {
+		args = append(args, redisKeyPrefix+key)
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+func (r *Redis) Clear(ctx context.Context) error {
+	cursor := "0"
+	for {
+		reply, err := r.do(ctx, "SCAN", cursor, "MATCH", redisKeyPrefix+"*", "COUNT", "100")
+		if err != nil {
+			return err
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return fmt.Errorf("redis SCAN: unexpected reply %v", reply)
+		}
+		next, _ := page[0].([]byte)
+		keys, _ := page[1].([]any)
+		if len(keys) > 0 {
+			args := []string{"DEL"}
+			for _, key := range keys {
+				b, _ := key.([]byte)
+				args = append(args, string(b))
+			}
+			if _, err := r.do(ctx, args...); err != nil {
+				return err
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// Close closes the idle connections.
+func (r *Redis) Close() error {
+	for {
+		select {
+		case conn := <-r.idle:
+			r.discard(conn)
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends one command and reads its reply. A connection that fails in any
+// way is closed rather than reused, since it may be out of step.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	conn, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		r.discard(conn)
+		return nil, err
+	}
+	select {
+	case r.idle <- conn:
+	default:
+		r.discard(conn)
+	}
+	return reply, err
+}
+
+// conn returns an idle connection, or opens a new one if there's room, or
+// waits for one to come free.
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-r.idle:
+		return conn, nil
+	default:
+	}
+	select {
+	case conn := <-r.idle:
+		return conn, nil
+	case r.open <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	dialer := net.Dialer{Timeout: redisTimeout}
+	nc, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		<-r.open
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, br: bufio.NewReader(nc)}
+	if r.password != "" {
+		if _, err := conn.do(ctx, "AUTH", r.password); err != nil {
+			r.discard(conn)
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(r.db)); err != nil {
+			r.discard(conn)
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// discard closes a connection and frees its slot.
+func (r *Redis) discard(conn *redisConn) {
+	conn.Close()
+	<-r.open
+}
+
+// redisError is an error reply from the server. The connection is still
+// usable after one.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+type redisConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *redisConn) do(ctx context.Context, args ...string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	c.SetDeadline(deadline)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.Conn, b.String()); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply reads a RESP reply: simple strings and bulk strings come back as
+// []byte, integers as int64, arrays as []any and nil bulk strings as nil. An
+// array holding an error reply is still read to the end, so the connection
+// stays in step for the next command.
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch prefix, rest := line[0], line[1:]; prefix {
+	case '+':
+		return []byte(rest), nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.br, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		var replyErr error
+		for i := range items {
+			items[i], err = c.readReply()
+			var redisErr redisError
+			if errors.As(err, &redisErr) {
+				if replyErr == nil {
+					replyErr = err
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if replyErr != nil {
+			return nil, replyErr
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
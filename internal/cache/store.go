@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+// Source is what a Store caches: the queries plus transactions, as the
+// service layer uses them.
+type Source interface {
+	database.Querier
+	InTx(ctx context.Context, fn func(q database.Querier) error) error
+}
+
+// Store is a Source that answers RetrieveSingleChirp, GetUserByID and
+// FindUserByEmail from a cache. Every other query goes straight through,
+// and the ones that change chirps or users drop the affected entries. A
+// failing cache is logged and bypassed rather than failing the query.
+//
+// Users from the cache never carry a password hash, so hashes stay out of
+// a cache server other services may share; GetUserPasswordHash reads one
+// from the source when a password has to be checked.
+//
+// Reads inside InTx aren't cached, so transactions always see the database;
+// their invalidations are applied once the transaction finishes. Bulk
+// deletions, which can't say which rows they removed, clear the whole cache.
+// A load that races a write can still put the old row back, but only until
+// its TTL runs out.
+type Store struct {
+	writes
+	src   Source
+	cache Cache
+	ttl   time.Duration
+	group singleflight.Group
+}
+
+// loadTimeout bounds a load that several callers are waiting on.
+const loadTimeout = 10 * time.Second
+
+// NewStore returns a Store that keeps entries in c for up to ttl.
+func NewStore(src Source, c Cache, ttl time.Duration) *Store {
+	s := &Store{src: src, cache: c, ttl: ttl}
+	s.writes = writes{Querier: src, invalidate: s.invalidate, clear: s.clear}
+	return s
+}
+
+func chirpKey(id uuid.UUID) string {
+	return "chirp:" + id.String()
+}
+
+func userKey(id uuid.UUID) string {
+	return "user:" + id.String()
+}
+
+// The email key only holds the user's ID, so changing an email can't leave
+// a stale copy of the user behind: a hit is checked against the user's
+// current email.
+func userEmailKey(email string) string {
+	return "user-email:" + email
+}
+
+func (s *Store) RetrieveSingleChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	return readThrough(ctx, s, chirpKey(id), func(ctx context.Context) (database.Chirp, error) {
+		return s.src.RetrieveSingleChirp(ctx, id)
+	})
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return readThrough(ctx, s, userKey(id), func(ctx context.Context) (database.User, error) {
+		user, err := s.src.GetUserByID(ctx, id)
+		return withoutPassword(user), err
+	})
+}
+
+func (s *Store) FindUserByEmail(ctx context.Context, email string) (database.User, error) {
+	key := userEmailKey(email)
+	if id, ok := lookup[uuid.UUID](ctx, s, key); ok {
+		user, err := s.GetUserByID(ctx, id)
+		if err == nil && user.Email == email {
+			return user, nil
+		}
+		s.invalidate(ctx, key)
+	}
+
+	user, err := s.src.FindUserByEmail(ctx, email)
+	if err != nil {
+		return user, err
+	}
+	user = withoutPassword(user)
+	store(ctx, s, userKey(user.ID), user)
+	store(ctx, s, key, user.ID)
+	return user, nil
+}
+
+func withoutPassword(user database.User) database.User {
+	user.HashedPassword = ""
+	return user
+}
+
+// InTx runs fn in a transaction on the source, then drops whatever the
+// transaction changed. Entries are dropped even if it rolled back, which
+// costs nothing but a reload.
+func (s *Store) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	var keys []string
+	var clearAll bool
+	err := s.src.InTx(ctx, func(q database.Querier) error {
+		return fn(writes{
+			Querier:    q,
+			invalidate: func(ctx context.Context, k ...string) { keys = append(keys, k...) },
+			clear:      func(ctx context.Context) { clearAll = true },
+		})
+	})
+	if clearAll {
+		s.clear(ctx)
+	} else if len(keys) > 0 {
+		s.invalidate(ctx, keys...)
+	}
+	return err
+}
+
+// readThrough returns the cached value under key, or loads it and caches it.
+// Concurrent misses for the same key share one load. Errors, including
+// sql.ErrNoRows, aren't cached.
+//
+// The shared load isn't cancelled with the caller that started it, or one
+// client going away would fail every request waiting on the same key; it
+// gets loadTimeout instead. Each caller still stops waiting when its own
+// context is done.
+func readThrough[T any](ctx context.Context, s *Store, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if v, ok := lookup[T](ctx, s, key); ok {
+		return v, nil
+	}
+	ch := s.group.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+		v, err := load(ctx)
+		if err != nil {
+			return v, err
+		}
+		store(ctx, s, key, v)
+		return v, nil
+	})
+	select {
+	case res := <-ch:
+		return res.Val.(T), res.Err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func lookup[T any](ctx context.Context, s *Store, key string) (T, bool) {
+	var v T
+	data, ok, err := s.cache.Get(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "Cache read failed", "key", key, "error", err)
+		return v, false
+	}
+	if !ok {
+		return v, false
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		slog.WarnContext(ctx, "Cached value is unreadable", "key", key, "error", err)
+		return v, false
+	}
+	return v, true
+}
+
+func store(ctx context.Context, s *Store, key string, v any) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = s.cache.Set(ctx, key, data, s.ttl)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Cache write failed", "key", key, "error", err)
+	}
+}
+
+func (s *Store) invalidate(ctx context.Context, keys ...string) {
+	// The write has already happened, so the entries have to go even if
+	// the caller has given up waiting.
+	err := s.cache.Delete(context.WithoutCancel(ctx), keys...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Cache invalidation failed; entries may be stale until they expire", "keys", keys, "error", err)
+	}
+}
+
+func (s *Store) clear(ctx context.Context) {
+	err := s.cache.Clear(context.WithoutCancel(ctx))
+	if err != nil {
+		slog.ErrorContext(ctx, "Clearing the cache failed; entries may be stale until they expire", "error", err)
+	}
+}
+
+// writes runs queries on a Querier and reports the cache entries that the
+// ones changing chirps and users make stale.
+type writes struct {
+	database.Querier
+	invalidate func(ctx context.Context, keys ...string)
+	clear      func(ctx context.Context)
+}
+
+func (w writes) DeleteChirps(ctx context.Context, id uuid.UUID) error {
+	err := w.Querier.DeleteChirps(ctx, id)
+	if err == nil {
+		w.invalidate(ctx, chirpKey(id))
+	}
+	return err
+}
+
+func (w writes) HideChirp(ctx context.Context, id uuid.UUID) error {
+	err := w.Querier.HideChirp(ctx, id)
+	if err == nil {
+		w.invalidate(ctx, chirpKey(id))
+	}
+	return err
+}
+
+func (w writes) UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error) {
+	user, err := w.Querier.UpdateUserEmail(ctx, arg)
+	if err == nil {
+		w.invalidate(ctx, userKey(arg.ID))
+	}
+	return user, err
+}
+
+func (w writes) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	user, err := w.Querier.UpdateUserPassword(ctx, arg)
+	if err == nil {
+		w.invalidate(ctx, userKey(arg.ID))
+	}
+	return user, err
+}
+
+func (w writes) UpgradeToChirpyRed(ctx context.Context, id uuid.UUID) error {
+	err := w.Querier.UpgradeToChirpyRed(ctx, id)
+	if err == nil {
+		w.invalidate(ctx, userKey(id))
+	}
+	return err
+}
+
+func (w writes) MarkEmailVerified(ctx context.Context, arg database.MarkEmailVerifiedParams) (int64, error) {
+	n, err := w.Querier.MarkEmailVerified(ctx, arg)
+	if err == nil {
+		w.invalidate(ctx, userKey(arg.ID))
+	}
+	return n, err
+}
+
+func (w writes) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	err := w.Querier.SuspendUser(ctx, id)
+	if err == nil {
+		w.invalidate(ctx, userKey(id))
+	}
+	return err
+}
+
+func (w writes) SoftDeleteUser(ctx context.Context, id uuid.UUID) error {
+	err := w.Querier.SoftDeleteUser(ctx, id)
+	if err == nil {
+		w.invalidate(ctx, userKey(id))
+	}
+	return err
+}
+
+func (w writes) RestoreUser(ctx context.Context, arg database.RestoreUserParams) (database.User, error) {
+	user, err := w.Querier.RestoreUser(ctx, arg)
+	if err == nil {
+		w.invalidate(ctx, userKey(arg.ID))
+	}
+	return user, err
+}
+
+func (w writes) DeleteUsers(ctx context.Context) error {
+	err := w.Querier.DeleteUsers(ctx)
+	if err == nil {
+		w.clear(ctx)
+	}
+	return err
+}
+
+func (w writes) HardDeleteExpiredUsers(ctx context.Context, graceDays int32) (int64, error) {
+	n, err := w.Querier.HardDeleteExpiredUsers(ctx, graceDays)
+	if err == nil && n > 0 {
+		w.clear(ctx)
+	}
+	return n, err
+}
@@ -0,0 +1,499 @@
+// Package config loads Chirpy's settings from defaults, an optional YAML or
+// TOML file, environment variables and command-line flags, in increasing
+// order of precedence.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/dandytron/chirpy.git/internal/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting. Each field's conf tag is its key in a config
+// file; the environment variable is the upper-cased key unless an env tag
+// says otherwise, and the flag is the key with dashes. Fields tagged secret
+// can also be read from a file named by <key>_file or <ENV>_FILE. Settings
+// required only to run the server, not subcommands such as migrate, are
+// tagged required:"server" and checked by CheckServer.
+type Config struct {
+	Platform    string `conf:"platform" required:"server" usage:"deployment platform; \"dev\" enables the reset endpoint"`
+	Store       string `conf:"store" usage:"\"postgres\", or \"memory\" to keep everything in memory for local development"`
+	DatabaseURL string `conf:"db_url" secret:"true" usage:"Postgres connection string; required with the postgres store"`
+	JWTSecret   string `conf:"jwt_secret" required:"server" secret:"true" usage:"secret used to sign access tokens"`
+	PolkaKey    string `conf:"polka_key" required:"server" secret:"true" usage:"API key Polka sends with webhooks"`
+	AdminEmail  string `conf:"admin_email" usage:"user made an admin once they verify this email"`
+	AutoMigrate bool   `conf:"auto_migrate" usage:"apply pending migrations on startup"`
+
+	Addr               string        `conf:"addr" usage:"address to listen on"`
+	TLSCertFile        string        `conf:"tls_cert_file" usage:"TLS certificate; enables HTTPS with tls_key_file"`
+	TLSKeyFile         string        `conf:"tls_key_file" usage:"TLS private key"`
+	ShutdownDrainDelay time.Duration `conf:"shutdown_drain_delay" usage:"how long readiness fails before connections are drained"`
+
+	AccessTokenTTL       time.Duration `conf:"access_token_ttl" usage:"lifetime of access tokens"`
+	RefreshTokenTTL      time.Duration `conf:"refresh_token_ttl" usage:"lifetime of refresh tokens"`
+	MaxChirpLength       int           `conf:"max_chirp_length" usage:"longest chirp allowed, in bytes"`
+	AccountSweepInterval time.Duration `conf:"account_sweep_interval" usage:"how often deleted accounts past their grace period are purged"`
+	CookieSecure         bool          `conf:"cookie_secure" usage:"mark session cookies Secure so they're only sent over HTTPS"`
+	CookieSameSite       string        `conf:"cookie_same_site" usage:"SameSite mode for session cookies: strict, lax or none"`
+	CookieDomain         string        `conf:"cookie_domain" usage:"domain session cookies are set for; empty means this host only"`
+
+	RateLimitStore    string           `conf:"rate_limit_store" usage:"\"memory\", or \"postgres\" to share rate limits between instances"`
+	RateLimitAuth     ratelimit.Policy `conf:"rate_limit_auth" usage:"requests per client to login, signup and token endpoints, as count/period such as 10/1m; 0 disables"`
+	RateLimitWrites   ratelimit.Policy `conf:"rate_limit_writes" usage:"requests per client that change data, as count/period; 0 disables"`
+	RateLimitReads    ratelimit.Policy `conf:"rate_limit_reads" usage:"requests per client that only read, as count/period; 0 disables"`
+	RateLimitWebhooks ratelimit.Policy `conf:"rate_limit_webhooks" usage:"webhook deliveries per sending address, as count/period; 0 disables"`
+	TrustedProxies    []netip.Prefix   `conf:"trusted_proxies" usage:"comma-separated IPs or CIDRs of proxies whose X-Forwarded-For header is believed"`
+
+	CORSAllowedOrigins    []string      `conf:"cors_allowed_origins" usage:"comma-separated origins browsers may call the API from, such as https://app.example.com, or * for any"`
+	CORSAllowedMethods    []string      `conf:"cors_allowed_methods" usage:"comma-separated methods allowed in cross-origin requests"`
+	CORSAllowedHeaders    []string      `conf:"cors_allowed_headers" usage:"comma-separated request headers allowed in cross-origin requests"`
+	CORSAllowCredentials  bool          `conf:"cors_allow_credentials" usage:"let allowed origins send cookies; can't be used with *"`
+	CORSMaxAge            time.Duration `conf:"cors_max_age" usage:"how long browsers may cache a preflight response"`
+	ContentSecurityPolicy string        `conf:"content_security_policy" usage:"Content-Security-Policy sent with every response; empty disables"`
+	HSTSMaxAge            time.Duration `conf:"hsts_max_age" usage:"Strict-Transport-Security max-age sent with HTTPS responses; 0 disables"`
+
+	Cache     string        `conf:"cache" usage:"cache for chirp and user lookups: \"memory\", \"redis\" to share it between instances, or \"none\""`
+	CacheSize int           `conf:"cache_size" usage:"most entries the memory cache holds"`
+	CacheTTL  time.Duration `conf:"cache_ttl" usage:"how long cached lookups are kept"`
+	RedisURL  string        `conf:"redis_url" secret:"true" usage:"redis://[:password@]host:port[/db]; required with the redis cache"`
+
+	LogFormat     string `conf:"log_format" usage:"\"text\" or \"json\""`
+	LogLevel      string `conf:"log_level" usage:"debug, info, warn or error"`
+	TraceExporter string `conf:"trace_exporter" env:"OTEL_TRACES_EXPORTER" usage:"\"otlp\", \"stdout\" or \"none\""`
+	ServiceName   string `conf:"service_name" env:"OTEL_SERVICE_NAME" usage:"service name reported in traces"`
+}
+
+// Default returns the settings used when no source sets a value.
+func Default() Config {
+	return Config{
+		Store:                "postgres",
+		Addr:                 ":8080",
+		ShutdownDrainDelay:   5 * time.Second,
+		AccessTokenTTL:       time.Hour,
+		RefreshTokenTTL:      60 * 24 * time.Hour,
+		MaxChirpLength:       140,
+		AccountSweepInterval: time.Hour,
+		CookieSecure:         true,
+		CookieSameSite:       "lax",
+		RateLimitStore:       "memory",
+		RateLimitAuth:        ratelimit.Policy{Limit: 10, Period: time.Minute},
+		RateLimitWrites:      ratelimit.Policy{Limit: 60, Period: time.Minute},
+		RateLimitReads:       ratelimit.Policy{Limit: 300, Period: time.Minute},
+		RateLimitWebhooks:    ratelimit.Policy{Limit: 120, Period: time.Minute},
+		CORSAllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+		CORSAllowedHeaders: []string{
+			"Authorization", "Content-Type", "Idempotency-Key", "If-Match", "If-Modified-Since",
+			"If-None-Match", "X-CSRF-Token", "X-Request-ID",
+		},
+		CORSMaxAge:            10 * time.Minute,
+		ContentSecurityPolicy: "default-src 'self'; img-src 'self' data:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		Cache:                 "memory",
+		CacheSize:             10000,
+		CacheTTL:              time.Minute,
+		LogFormat:             "text",
+		LogLevel:              "info",
+		TraceExporter:         "none",
+		ServiceName:           "chirpy",
+	}
+}
+
+// EnvConfigFile names the environment variable that points at a config file
+// when no -config flag is given.
+const EnvConfigFile = "CHIRPY_CONFIG"
+
+type field struct {
+	key      string
+	env      string
+	usage    string
+	required string
+	secret   bool
+	index    int
+}
+
+func fields() []field {
+	t := reflect.TypeOf(Config{})
+	out := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := f.Tag.Get("conf")
+		env := f.Tag.Get("env")
+		if env == "" {
+			env = strings.ToUpper(key)
+		}
+		out = append(out, field{
+			key:      key,
+			env:      env,
+			usage:    f.Tag.Get("usage"),
+			required: f.Tag.Get("required"),
+			secret:   f.Tag.Get("secret") == "true",
+			index:    i,
+		})
+	}
+	return out
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// NewFlagSet returns a flag set with -config and a flag for every setting.
+// Flags are parsed by Load; callers can add their own before that.
+func NewFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.String("config", "", "path to a YAML or TOML config file (default $"+EnvConfigFile+")")
+	for _, f := range fields() {
+		usage := f.usage + " ($" + f.env + ")"
+		fs.String(flagName(f.key), "", usage)
+		if f.secret {
+			fs.String(flagName(f.key)+"-file", "", "read "+f.key+" from this file ($"+f.env+"_FILE)")
+		}
+	}
+	return fs
+}
+
+// Load parses args with fs (from NewFlagSet) and builds the configuration.
+// Every problem found is reported at once, joined into a single error.
+func Load(fs *flag.FlagSet, args []string, getenv func(string) string) (Config, error) {
+	err := fs.Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+	flags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+
+	cfg := Default()
+	var errs []error
+
+	path := flags["config"]
+	if path == "" {
+		path = getenv(EnvConfigFile)
+	}
+	fileValues := map[string]string{}
+	if path != "" {
+		fileValues, err = readFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	known := map[string]bool{}
+	v := reflect.ValueOf(&cfg).Elem()
+	for _, f := range fields() {
+		known[f.key] = true
+		known[f.key+"_file"] = f.secret
+
+		// Later sources override earlier ones.
+		sources := []struct {
+			name     string
+			value    string
+			fromFile string
+		}{
+			{"config file", fileValues[f.key], fileValues[f.key+"_file"]},
+			{"$" + f.env, getenv(f.env), getenv(f.env + "_FILE")},
+			{"-" + flagName(f.key), flags[flagName(f.key)], flags[flagName(f.key)+"-file"]},
+		}
+		for _, source := range sources {
+			value := source.value
+			if f.secret && source.fromFile != "" {
+				if value != "" {
+					errs = append(errs, fmt.Errorf("%s: set both %s and its _file variant", f.key, source.name))
+					continue
+				}
+				value, err = readSecret(source.fromFile)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+					continue
+				}
+			}
+			if value == "" {
+				continue
+			}
+			err = setField(v.Field(f.index), value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s from %s: %w", f.key, source.name, err))
+			}
+		}
+	}
+
+	var unknown []string
+	for key := range fileValues {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown setting in %s", key, path))
+	}
+
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// CheckServer reports the settings that are missing but needed to run the
+// server.
+func (c Config) CheckServer() error {
+	return errors.Join(c.missing("server")...)
+}
+
+func (c Config) missing(required string) []error {
+	var errs []error
+	v := reflect.ValueOf(c)
+	for _, f := range fields() {
+		if f.required == required && v.Field(f.index).IsZero() {
+			errs = append(errs, fmt.Errorf("%s is required (set $%s)", f.key, f.env))
+		}
+	}
+	return errs
+}
+
+func (c Config) validate() []error {
+	errs := c.missing("true")
+
+	switch c.Store {
+	case "postgres":
+		if c.DatabaseURL == "" {
+			errs = append(errs, errors.New("db_url is required (set $DB_URL)"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("store must be \"postgres\" or \"memory\", got %q", c.Store))
+	}
+
+	switch c.RateLimitStore {
+	case "memory":
+	case "postgres":
+		if c.Store != "postgres" {
+			errs = append(errs, errors.New("rate_limit_store \"postgres\" needs the postgres store"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit_store must be \"memory\" or \"postgres\", got %q", c.RateLimitStore))
+	}
+
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			if c.CORSAllowCredentials {
+				errs = append(errs, errors.New("cors_allow_credentials can't be used with the * origin"))
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			errs = append(errs, fmt.Errorf("cors_allowed_origins: %q must be a scheme and host such as https://app.example.com", origin))
+		}
+	}
+	if c.CORSMaxAge < 0 {
+		errs = append(errs, errors.New("cors_max_age can't be negative"))
+	}
+	if c.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("hsts_max_age can't be negative"))
+	}
+
+	switch c.Cache {
+	case "memory", "none":
+	case "redis":
+		if c.RedisURL == "" {
+			errs = append(errs, errors.New("redis_url is required with the redis cache (set $REDIS_URL)"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cache must be \"memory\", \"redis\" or \"none\", got %q", c.Cache))
+	}
+	if c.CacheSize <= 0 {
+		errs = append(errs, errors.New("cache_size must be positive"))
+	}
+	if c.CacheTTL <= 0 {
+		errs = append(errs, errors.New("cache_ttl must be positive"))
+	}
+
+	switch c.CookieSameSite {
+	case "strict", "lax":
+	case "none":
+		if !c.CookieSecure {
+			errs = append(errs, errors.New("cookie_same_site \"none\" needs cookie_secure"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cookie_same_site must be \"strict\", \"lax\" or \"none\", got %q", c.CookieSameSite))
+	}
+
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("log_format must be \"text\" or \"json\", got %q", c.LogFormat))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs = append(errs, fmt.Errorf("log_level %q is not a valid level", c.LogLevel))
+	}
+	switch c.TraceExporter {
+	case "none", "stdout", "console", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("trace_exporter must be \"otlp\", \"stdout\" or \"none\", got %q", c.TraceExporter))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file and tls_key_file must be set together"))
+	}
+	if c.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("access_token_ttl must be positive"))
+	}
+	if c.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("refresh_token_ttl must be positive"))
+	}
+	if c.AccountSweepInterval <= 0 {
+		errs = append(errs, errors.New("account_sweep_interval must be positive"))
+	}
+	if c.ShutdownDrainDelay < 0 {
+		errs = append(errs, errors.New("shutdown_drain_delay can't be negative"))
+	}
+	if c.MaxChirpLength <= 0 {
+		errs = append(errs, errors.New("max_chirp_length must be positive"))
+	}
+	return errs
+}
+
+func setField(v reflect.Value, value string) error {
+	switch v.Interface().(type) {
+	case string:
+		v.SetString(value)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case ratelimit.Policy:
+		p, err := ratelimit.ParsePolicy(value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(p))
+	case []string:
+		v.Set(reflect.ValueOf(splitList(value)))
+	case []netip.Prefix:
+		prefixes, err := parsePrefixes(value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(prefixes))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePrefixes parses a comma-separated list of CIDRs and bare IPs, which
+// stand for just that address.
+func parsePrefixes(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// readFile reads a flat YAML or TOML file, chosen by extension, into
+// string values.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read config file: %w", err)
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[key] = fileValue(value)
+	}
+	return values, nil
+}
+
+// fileValue formats a value from a config file the way the environment and
+// flags spell it, so lists come out comma-separated.
+func fileValue(value interface{}) string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	items := make([]string, len(list))
+	for i, item := range list {
+		items[i] = fmt.Sprint(item)
+	}
+	return strings.Join(items, ",")
+}
+
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read secret file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(contents), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func envFunc(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	configFile := writeFile(t, "chirpy.yaml", `
+platform: dev
+db_url: postgres://file
+jwt_secret: from-file
+polka_key: from-file
+addr: ":9000"
+max_chirp_length: 280
+access_token_ttl: 30m
+`)
+	env := map[string]string{
+		"JWT_SECRET": "from-env",
+		"ADDR":       ":9001",
+	}
+	args := []string{"-config", configFile, "-addr", ":9002"}
+
+	cfg, err := Load(NewFlagSet("chirpy"), args, envFunc(env))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.DatabaseURL != "postgres://file" {
+		t.Errorf("DatabaseURL = %q, want value from file", cfg.DatabaseURL)
+	}
+	if cfg.JWTSecret != "from-env" {
+		t.Errorf("JWTSecret = %q, want env to override file", cfg.JWTSecret)
+	}
+	if cfg.Addr != ":9002" {
+		t.Errorf("Addr = %q, want flag to override env", cfg.Addr)
+	}
+	if cfg.MaxChirpLength != 280 {
+		t.Errorf("MaxChirpLength = %d, want 280", cfg.MaxChirpLength)
+	}
+	if cfg.AccessTokenTTL != 30*time.Minute {
+		t.Errorf("AccessTokenTTL = %v, want 30m", cfg.AccessTokenTTL)
+	}
+	if cfg.RefreshTokenTTL != Default().RefreshTokenTTL {
+		t.Errorf("RefreshTokenTTL = %v, want default", cfg.RefreshTokenTTL)
+	}
+}
+
+func TestLoadTOMLAndSecretFiles(t *testing.T) {
+	secretFile := writeFile(t, "jwt_secret", "from-secret-file\n")
+	configFile := writeFile(t, "chirpy.toml", `
+platform = "prod"
+db_url = "postgres://file"
+polka_key = "key"
+`)
+	env := map[string]string{
+		EnvConfigFile:     configFile,
+		"JWT_SECRET_FILE": secretFile,
+	}
+
+	cfg, err := Load(NewFlagSet("chirpy"), nil, envFunc(env))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.JWTSecret != "from-secret-file" {
+		t.Errorf("JWTSecret = %q, want trimmed contents of the secret file", cfg.JWTSecret)
+	}
+	if cfg.Platform != "prod" {
+		t.Errorf("Platform = %q, want prod", cfg.Platform)
+	}
+}
+
+func TestLoadAggregatesErrors(t *testing.T) {
+	configFile := writeFile(t, "chirpy.yaml", `
+platform: dev
+log_format: xml
+colour: blue
+`)
+	env := map[string]string{
+		"ACCESS_TOKEN_TTL": "soon",
+		"TLS_CERT_FILE":    "cert.pem",
+	}
+
+	_, err := Load(NewFlagSet("chirpy"), []string{"-config", configFile}, envFunc(env))
+	if err == nil {
+		t.Fatal("Load() error = nil, want error")
+	}
+	for _, want := range []string{
+		"db_url is required",
+		"log_format",
+		"colour: unknown setting",
+		"access_token_ttl from $ACCESS_TOKEN_TTL",
+		"tls_cert_file and tls_key_file",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestCheckServer(t *testing.T) {
+	env := map[string]string{
+		"DB_URL":       "postgres://env",
+		"AUTO_MIGRATE": "true",
+	}
+
+	cfg, err := Load(NewFlagSet("chirpy"), []string{"migrate", "up"}, envFunc(env))
+	if err != nil {
+		t.Fatalf("Load() error = %v, want subcommands to need only db_url", err)
+	}
+	if !cfg.AutoMigrate {
+		t.Error("AutoMigrate = false, want true")
+	}
+
+	err = cfg.CheckServer()
+	if err == nil {
+		t.Fatal("CheckServer() error = nil, want error")
+	}
+	for _, want := range []string{"platform", "jwt_secret", "polka_key"} {
+		if !strings.Contains(err.Error(), want+" is required") {
+			t.Errorf("CheckServer() error missing %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadMemoryStoreNeedsNoDatabase(t *testing.T) {
+	cfg, err := Load(NewFlagSet("chirpy"), []string{"-store", "memory"}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Store != "memory" {
+		t.Errorf("Store = %q, want memory", cfg.Store)
+	}
+
+	_, err = Load(NewFlagSet("chirpy"), []string{"-store", "sqlite"}, envFunc(nil))
+	if err == nil || !strings.Contains(err.Error(), "store must be") {
+		t.Errorf("Load() error = %v, want invalid store", err)
+	}
+}
+
+func TestLoadRateLimits(t *testing.T) {
+	env := map[string]string{
+		"DB_URL":           "postgres://env",
+		"RATE_LIMIT_AUTH":  "5/30s",
+		"RATE_LIMIT_READS": "0",
+		"TRUSTED_PROXIES":  "10.0.0.0/8, 192.168.1.7",
+		"RATE_LIMIT_STORE": "postgres",
+	}
+	cfg, err := Load(NewFlagSet("chirpy"), nil, envFunc(env))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.RateLimitAuth.Limit != 5 || cfg.RateLimitAuth.Period != 30*time.Second {
+		t.Errorf("RateLimitAuth = %v, want 5/30s", cfg.RateLimitAuth)
+	}
+	if cfg.RateLimitReads.Enabled() {
+		t.Errorf("RateLimitReads = %v, want disabled", cfg.RateLimitReads)
+	}
+	if len(cfg.TrustedProxies) != 2 || cfg.TrustedProxies[1].String() != "192.168.1.7/32" {
+		t.Errorf("TrustedProxies = %v, want the CIDR and a single address", cfg.TrustedProxies)
+	}
+
+	args := []string{"-store", "memory", "-rate-limit-store", "postgres", "-rate-limit-writes", "lots", "-trusted-proxies", "proxy"}
+	_, err = Load(NewFlagSet("chirpy"), args, envFunc(nil))
+	for _, want := range []string{"rate_limit_store", "rate_limit_writes", "trusted_proxies"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestLoadCORS(t *testing.T) {
+	env := map[string]string{
+		"DB_URL":               "postgres://env",
+		"CORS_ALLOWED_ORIGINS": "https://app.example.com, http://localhost:5173",
+		"CORS_ALLOWED_METHODS": "GET,POST",
+	}
+	cfg, err := Load(NewFlagSet("chirpy"), nil, envFunc(env))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.CORSAllowedOrigins) != 2 || cfg.CORSAllowedOrigins[1] != "http://localhost:5173" {
+		t.Errorf("CORSAllowedOrigins = %q, want both origins", cfg.CORSAllowedOrigins)
+	}
+	if len(cfg.CORSAllowedMethods) != 2 {
+		t.Errorf("CORSAllowedMethods = %q, want GET and POST", cfg.CORSAllowedMethods)
+	}
+
+	// Config files can write lists as lists.
+	for name, contents := range map[string]string{
+		"chirpy.yaml": "cors_allowed_origins:\n  - https://app.example.com\n  - http://localhost:5173\ntrusted_proxies: [10.0.0.0/8, 192.168.1.1]\n",
+		"chirpy.toml": "cors_allowed_origins = [\"https://app.example.com\", \"http://localhost:5173\"]\ntrusted_proxies = [\"10.0.0.0/8\", \"192.168.1.1\"]\n",
+	} {
+		fileEnv := map[string]string{"DB_URL": "postgres://env", EnvConfigFile: writeFile(t, name, contents)}
+		cfg, err := Load(NewFlagSet("chirpy"), nil, envFunc(fileEnv))
+		if err != nil {
+			t.Fatalf("Load(%s) error = %v", name, err)
+		}
+		if len(cfg.CORSAllowedOrigins) != 2 || cfg.CORSAllowedOrigins[1] != "http://localhost:5173" {
+			t.Errorf("CORSAllowedOrigins from %s = %q, want both origins", name, cfg.CORSAllowedOrigins)
+		}
+		if len(cfg.TrustedProxies) != 2 || cfg.TrustedProxies[1].String() != "192.168.1.1/32" {
+			t.Errorf("TrustedProxies from %s = %v, want both proxies", name, cfg.TrustedProxies)
+		}
+	}
+
+	args := []string{"-cors-allowed-origins", "*,app.example.com", "-cors-allow-credentials", "true"}
+	_, err = Load(NewFlagSet("chirpy"), args, envFunc(env))
+	for _, want := range []string{"cors_allow_credentials", "app.example.com"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() error = %v, want it to mention %s", err, want)
+		}
+	}
+}
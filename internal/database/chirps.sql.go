@@ -20,7 +20,7 @@ VALUES (
     $1, 
     $2
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at
 `
 
 type CreateChirpParams struct {
@@ -37,6 +37,7 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
 	)
 	return i, err
 }
@@ -51,13 +52,75 @@ func (q *Queries) DeleteChirps(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps SET hidden_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const listChirpsByAuthor = `-- name: ListChirpsByAuthor :many
+SELECT id, created_at, updated_at, body, user_id, hidden_at FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByAuthor, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const retrieveAllChirps = `-- name: RetrieveAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, hidden_at FROM chirps
+WHERE hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.deleted_at IS NOT NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = $1)
+    OR (user_blocks.blocker_id = $1 AND user_blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = $1
+    AND user_mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) RetrieveAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, retrieveAllChirps)
+func (q *Queries) RetrieveAllChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, retrieveAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +134,7 @@ func (q *Queries) RetrieveAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -86,7 +150,7 @@ func (q *Queries) RetrieveAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const retrieveSingleChirp = `-- name: RetrieveSingleChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, hidden_at FROM chirps
 WHERE id = $1
 `
 
@@ -99,6 +163,42 @@ func (q *Queries) RetrieveSingleChirp(ctx context.Context, id uuid.UUID) (Chirp,
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const retrieveVisibleChirp = `-- name: RetrieveVisibleChirp :one
+SELECT id, created_at, updated_at, body, user_id, hidden_at FROM chirps
+WHERE id = $1
+AND hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.deleted_at IS NOT NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+    AND user_blocks.blocked_id = $2
+)
+`
+
+type RetrieveVisibleChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) RetrieveVisibleChirp(ctx context.Context, arg RetrieveVisibleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, retrieveVisibleChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
 	)
 	return i, err
 }
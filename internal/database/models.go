@@ -17,6 +17,88 @@ type Chirp struct {
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	HiddenAt  sql.NullTime
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type EmailVerificationToken struct {
+	Token     string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type IdempotencyKey struct {
+	Scope               string
+	Key                 string
+	Fingerprint         string
+	LockedAt            time.Time
+	ExpiresAt           time.Time
+	ResponseStatus      int32
+	ResponseContentType string
+	ResponseBody        []byte
+	LockToken           uuid.UUID
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type MessageDeletion struct {
+	MessageID uuid.UUID
+	UserID    uuid.UUID
+	DeletedAt time.Time
+}
+
+type ModerationAction struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	ModeratorID   uuid.NullUUID
+	ReportID      uuid.NullUUID
+	Action        string
+	TargetUserID  uuid.NullUUID
+	TargetChirpID uuid.NullUUID
+	Note          string
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ActorID   uuid.NullUUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID
+	Type      string
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
+type RateLimit struct {
+	Key    string
+	FullAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,11 +110,57 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
-type User struct {
+type Report struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    sql.NullBool
+	ReporterID     uuid.UUID
+	ReportedUserID uuid.UUID
+	ChirpID        uuid.NullUUID
+	Reason         string
+	Details        string
+	Status         string
+	AssigneeID     uuid.NullUUID
+	ResolvedAt     sql.NullTime
+}
+
+type Role struct {
+	Name        string
+	Description string
+}
+
+type RolePermission struct {
+	Role       string
+	Permission string
+}
+
+type User struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     sql.NullBool
+	SuspendedAt     sql.NullTime
+	DeletedAt       sql.NullTime
+	EmailVerifiedAt sql.NullTime
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserRole struct {
+	UserID    uuid.UUID
+	Role      string
+	GrantedAt time.Time
+	GrantedBy uuid.NullUUID
 }
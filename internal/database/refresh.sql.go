@@ -45,11 +45,13 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red FROM users
+SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red, users.suspended_at, users.deleted_at, users.email_verified_at FROM users
 JOIN refresh_tokens ON users.id = refresh_tokens.user_id
 WHERE refresh_tokens.token = $1
 AND revoked_at IS NULL
 AND expires_at > NOW()
+AND users.suspended_at IS NULL
+AND users.deleted_at IS NULL
 `
 
 func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (User, error) {
@@ -62,6 +64,9 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -86,3 +91,15 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) (Refresh
 	)
 	return i, err
 }
+
+const revokeAllRefreshTokensForUser = `-- name: RevokeAllRefreshTokensForUser :exec
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeAllRefreshTokensForUser, userID)
+	return err
+}
@@ -10,14 +10,28 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countUsersByIDs = `-- name: CountUsersByIDs :one
+SELECT COUNT(*) FROM users
+WHERE id = ANY($1::uuid[])
+AND deleted_at IS NULL
+`
+
+func (q *Queries) CountUsersByIDs(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsersByIDs, pq.Array(ids))
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, hashed_password, email)
 VALUES (
     $1, $2, $3, $4, $5
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, deleted_at, email_verified_at
 `
 
 type CreateUserParams struct {
@@ -44,6 +58,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -58,7 +75,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const findUserByEmail = `-- name: FindUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, deleted_at, email_verified_at FROM users
 WHERE email = $1
 `
 
@@ -72,24 +89,195 @@ func (q *Queries) FindUserByEmail(ctx context.Context, email string) (User, erro
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, deleted_at, email_verified_at FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserPasswordHash = `-- name: GetUserPasswordHash :one
+SELECT hashed_password FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserPasswordHash(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRowContext(ctx, getUserPasswordHash, id)
+	var hashed_password string
+	err := row.Scan(&hashed_password)
+	return hashed_password, err
+}
+
+const hardDeleteExpiredUsers = `-- name: HardDeleteExpiredUsers :execrows
+DELETE FROM users
+WHERE deleted_at < NOW() - ($1::int * INTERVAL '1 day')
+`
+
+func (q *Queries) HardDeleteExpiredUsers(ctx context.Context, graceDays int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hardDeleteExpiredUsers, graceDays)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listRecentUsers = `-- name: ListRecentUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, deleted_at, email_verified_at FROM users
+WHERE created_at >= $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListRecentUsersParams struct {
+	Since     time.Time
+	PageLimit int32
+}
+
+func (q *Queries) ListRecentUsers(ctx context.Context, arg ListRecentUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listRecentUsers, arg.Since, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.SuspendedAt,
+			&i.DeletedAt,
+			&i.EmailVerifiedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1
+AND deleted_at > NOW() - ($2::int * INTERVAL '1 day')
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, deleted_at, email_verified_at
+`
+
+type RestoreUserParams struct {
+	ID        uuid.UUID
+	GraceDays int32
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, arg.ID, arg.GraceDays)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
-const updateUser = `-- name: UpdateUser :one
-UPDATE users SET email = $2, hashed_password = $3, updated_at = NOW()
+const softDeleteUser = `-- name: SoftDeleteUser :exec
+UPDATE users SET deleted_at = NOW(), updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
 `
 
-type UpdateUserParams struct {
+func (q *Queries) SoftDeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, softDeleteUser, id)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users SET suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, id)
+	return err
+}
+
+const updateUserEmail = `-- name: UpdateUserEmail :one
+UPDATE users SET email = $2, email_verified_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, deleted_at, email_verified_at
+`
+
+type UpdateUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmail, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, suspended_at, deleted_at, email_verified_at
+`
+
+type UpdateUserPasswordParams struct {
 	ID             uuid.UUID
-	Email          string
 	HashedPassword string
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.ID, arg.Email, arg.HashedPassword)
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -98,6 +286,9 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.DeletedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,174 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces any value the logger must never write out.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are always redacted,
+// compared case-insensitively and ignoring "-" and "_".
+var sensitiveKeys = map[string]struct{}{
+	"authorization":   {},
+	"password":        {},
+	"currentpassword": {},
+	"hashedpassword":  {},
+	"token":           {},
+	"accesstoken":     {},
+	"refreshtoken":    {},
+	"apikey":          {},
+	"secret":          {},
+	"jwtsecret":       {},
+	"polkakey":        {},
+	"cookie":          {},
+	"setcookie":       {},
+}
+
+// credentialPattern matches credentials embedded in free text, such as an
+// Authorization header quoted in an error message.
+var credentialPattern = regexp.MustCompile(`(?i)\b(bearer|apikey)\s+[^\s"',]+`)
+
+// Options configures New.
+type Options struct {
+	// Format is "json" or "text". Anything else falls back to text.
+	Format string
+	// Level is "debug", "info", "warn" or "error". Defaults to info.
+	Level string
+}
+
+// New returns a logger writing to w in the configured format. Every record
+// passes through Redact, so handlers can't leak credentials by accident, and
+// records logged with a context from WithRequestID carry its request ID.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level := slog.LevelInfo
+	if opts.Level != "" {
+		err := level.UnmarshalText([]byte(opts.Level))
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level %q", opts.Level)
+		}
+	}
+
+	handlerOpts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: replaceAttr,
+	}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	return Redact(a)
+}
+
+// Redact blanks out the value of a sensitive attribute, scrubs credentials
+// from string and error values, and masks sensitive entries in http.Header.
+func Redact(a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, scrub(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = Redact(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch val := v.Any().(type) {
+		case http.Header:
+			return slog.Any(a.Key, redactHeader(val))
+		case error:
+			return slog.String(a.Key, scrub(val.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, scrub(val.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+func isSensitiveKey(key string) bool {
+	normalized := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	_, ok := sensitiveKeys[normalized]
+	return ok
+}
+
+func scrub(s string) string {
+	return credentialPattern.ReplaceAllString(s, "$1 "+Redacted)
+}
+
+func redactHeader(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+	for key, values := range h {
+		if isSensitiveKey(key) {
+			redacted[key] = []string{Redacted}
+			continue
+		}
+		scrubbed := make([]string, len(values))
+		for i, value := range values {
+			scrubbed[i] = scrub(value)
+		}
+		redacted[key] = scrubbed
+	}
+	return redacted
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose log records carry the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the ID stored by WithRequestID, or "".
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// contextHandler adds the request ID and trace ID from the record's context,
+// so any slog.*Context call made while serving a request can be correlated
+// with it.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewRedactsSecrets(t *testing.T) {
+	secrets := []string{"hunter2", "abc.def.ghi", "f271c81ff7084ee5b99a5091b42d486e"}
+
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+	}{
+		{
+			name: "Sensitive keys",
+			log: func(logger *slog.Logger) {
+				logger.Info("login", "password", "hunter2", "refresh_token", "abc.def.ghi")
+			},
+		},
+		{
+			name: "Sensitive keys in a group",
+			log: func(logger *slog.Logger) {
+				logger.Info("login", slog.Group("params", "Current-Password", "hunter2"))
+			},
+		},
+		{
+			name: "Authorization header",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", "headers", http.Header{
+					"Authorization": {"Bearer abc.def.ghi"},
+					"Accept":        {"application/json"},
+				})
+			},
+		},
+		{
+			name: "Credential in message and error",
+			log: func(logger *slog.Logger) {
+				logger.Error("bad header ApiKey f271c81ff7084ee5b99a5091b42d486e", "error", errors.New("malformed: Bearer abc.def.ghi"))
+			},
+		},
+	}
+
+	for _, format := range []string{"json", "text"} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				buf := &bytes.Buffer{}
+				logger, err := New(buf, Options{Format: format})
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+				tt.log(logger)
+
+				out := buf.String()
+				for _, secret := range secrets {
+					if strings.Contains(out, secret) {
+						t.Errorf("log output contains %q: %s", secret, out)
+					}
+				}
+				if !strings.Contains(out, Redacted) {
+					t.Errorf("log output doesn't contain %q: %s", Redacted, out)
+				}
+			})
+		}
+	}
+}
+
+func TestNewInvalidLevel(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, Options{Level: "loud"})
+	if err == nil {
+		t.Error("New() error = nil, want error for unknown level")
+	}
+}
+
+func TestNewAddsRequestID(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger, err := New(buf, Options{Format: "json"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := WithRequestID(context.Background(), "req-123")
+	logger.InfoContext(ctx, "hello")
+
+	if !strings.Contains(buf.String(), `"request_id":"req-123"`) {
+		t.Errorf("log output missing request ID: %s", buf.String())
+	}
+}
@@ -0,0 +1,93 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.userExists(arg.UserID) {
+		return database.Chirp{}, foreignKeyViolation("chirps_user_id_fkey")
+	}
+	now := s.timestamp()
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+	}
+	s.chirps = append(s.chirps, chirp)
+	return chirp, nil
+}
+
+func (s *Store) DeleteChirps(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteChirp(id)
+	return nil
+}
+
+func (s *Store) HideChirp(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.chirpIndex(id); i >= 0 {
+		now := s.timestamp()
+		s.chirps[i].HiddenAt = nullTime(now)
+		s.chirps[i].UpdatedAt = now
+	}
+	return nil
+}
+
+func (s *Store) ListChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirps := filter(s.chirps, func(c database.Chirp) bool { return c.UserID == userID })
+	slices.SortStableFunc(chirps, byCreatedAt(func(c database.Chirp) time.Time { return c.CreatedAt }, false))
+	return chirps, nil
+}
+
+func (s *Store) RetrieveAllChirps(ctx context.Context, viewerID uuid.UUID) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirps := filter(s.chirps, func(c database.Chirp) bool {
+		return !c.HiddenAt.Valid &&
+			!s.userDeleted(c.UserID) &&
+			!s.blocked(c.UserID, viewerID) &&
+			!s.blocked(viewerID, c.UserID) &&
+			!s.muted(viewerID, c.UserID)
+	})
+	slices.SortStableFunc(chirps, byCreatedAt(func(c database.Chirp) time.Time { return c.CreatedAt }, false))
+	return chirps, nil
+}
+
+func (s *Store) RetrieveSingleChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.chirpIndex(id)
+	if i < 0 {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return s.chirps[i], nil
+}
+
+func (s *Store) RetrieveVisibleChirp(ctx context.Context, arg database.RetrieveVisibleChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.chirpIndex(arg.ID)
+	if i < 0 {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	chirp := s.chirps[i]
+	if chirp.HiddenAt.Valid || s.userDeleted(chirp.UserID) || s.blocked(chirp.UserID, arg.ViewerID) {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
@@ -0,0 +1,54 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateEmailVerificationToken(ctx context.Context, arg database.CreateEmailVerificationTokenParams) (database.EmailVerificationToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.emailVerificationTokens, func(t database.EmailVerificationToken) bool { return t.Token == arg.Token }) {
+		return database.EmailVerificationToken{}, uniqueViolation("email_verification_tokens_pkey")
+	}
+	if !s.userExists(arg.UserID) {
+		return database.EmailVerificationToken{}, foreignKeyViolation("email_verification_tokens_user_id_fkey")
+	}
+	token := database.EmailVerificationToken{
+		Token:     arg.Token,
+		UserID:    arg.UserID,
+		Email:     arg.Email,
+		CreatedAt: s.timestamp(),
+		ExpiresAt: arg.ExpiresAt,
+	}
+	s.emailVerificationTokens = append(s.emailVerificationTokens, token)
+	return token, nil
+}
+
+func (s *Store) InvalidateEmailVerificationTokens(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.timestamp()
+	for i, t := range s.emailVerificationTokens {
+		if t.UserID == userID && !t.UsedAt.Valid {
+			s.emailVerificationTokens[i].UsedAt = nullTime(now)
+		}
+	}
+	return nil
+}
+
+func (s *Store) UseEmailVerificationToken(ctx context.Context, token string) (database.EmailVerificationToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.timestamp()
+	i := slices.IndexFunc(s.emailVerificationTokens, func(t database.EmailVerificationToken) bool { return t.Token == token })
+	if i < 0 || s.emailVerificationTokens[i].UsedAt.Valid || !s.emailVerificationTokens[i].ExpiresAt.After(now) {
+		return database.EmailVerificationToken{}, sql.ErrNoRows
+	}
+	s.emailVerificationTokens[i].UsedAt = nullTime(now)
+	return s.emailVerificationTokens[i], nil
+}
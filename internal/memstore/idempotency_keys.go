@@ -0,0 +1,83 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+)
+
+func (s *Store) ClaimIdempotencyKey(ctx context.Context, arg database.ClaimIdempotencyKeyParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := database.IdempotencyKey{
+		Scope:        arg.Scope,
+		Key:          arg.Key,
+		Fingerprint:  arg.Fingerprint,
+		LockedAt:     arg.LockedAt,
+		ExpiresAt:    arg.ExpiresAt,
+		ResponseBody: []byte{},
+		LockToken:    arg.LockToken,
+	}
+	i := s.idempotencyKeyIndex(arg.Scope, arg.Key)
+	if i < 0 {
+		s.idempotencyKeys = append(s.idempotencyKeys, claimed)
+		return 1, nil
+	}
+	existing := s.idempotencyKeys[i]
+	expired := !existing.ExpiresAt.After(arg.LockedAt)
+	abandoned := existing.ResponseStatus == 0 && existing.LockedAt.Before(arg.StaleBefore)
+	if !expired && !abandoned {
+		return 0, nil
+	}
+	s.idempotencyKeys[i] = claimed
+	return 1, nil
+}
+
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, arg database.CompleteIdempotencyKeyParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.idempotencyKeyIndex(arg.Scope, arg.Key)
+	if i < 0 || s.idempotencyKeys[i].LockToken != arg.LockToken {
+		return 0, nil
+	}
+	s.idempotencyKeys[i].ResponseStatus = arg.ResponseStatus
+	s.idempotencyKeys[i].ResponseContentType = arg.ResponseContentType
+	s.idempotencyKeys[i].ResponseBody = slices.Clone(arg.ResponseBody)
+	return 1, nil
+}
+
+func (s *Store) DeleteExpiredIdempotencyKeys(ctx context.Context, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.idempotencyKeys)
+	s.idempotencyKeys = slices.DeleteFunc(s.idempotencyKeys, func(k database.IdempotencyKey) bool { return !k.ExpiresAt.After(expiresAt) })
+	return int64(before - len(s.idempotencyKeys)), nil
+}
+
+func (s *Store) DeleteIdempotencyKey(ctx context.Context, arg database.DeleteIdempotencyKeyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.idempotencyKeyIndex(arg.Scope, arg.Key); i >= 0 && s.idempotencyKeys[i].LockToken == arg.LockToken {
+		s.idempotencyKeys = slices.Delete(s.idempotencyKeys, i, i+1)
+	}
+	return nil
+}
+
+func (s *Store) GetIdempotencyKey(ctx context.Context, arg database.GetIdempotencyKeyParams) (database.IdempotencyKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.idempotencyKeyIndex(arg.Scope, arg.Key)
+	if i < 0 {
+		return database.IdempotencyKey{}, sql.ErrNoRows
+	}
+	key := s.idempotencyKeys[i]
+	key.ResponseBody = slices.Clone(key.ResponseBody)
+	return key, nil
+}
+
+func (s *Store) idempotencyKeyIndex(scope, key string) int {
+	return slices.IndexFunc(s.idempotencyKeys, func(k database.IdempotencyKey) bool { return k.Scope == scope && k.Key == key })
+}
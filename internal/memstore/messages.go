@@ -0,0 +1,192 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateConversation(ctx context.Context, memberIds []uuid.UUID) (database.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, id := range memberIds {
+		if !s.userExists(id) {
+			return database.Conversation{}, foreignKeyViolation("conversation_members_user_id_fkey")
+		}
+		if slices.Contains(memberIds[:i], id) {
+			return database.Conversation{}, uniqueViolation("conversation_members_pkey")
+		}
+	}
+	now := s.timestamp()
+	conversation := database.Conversation{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.conversations = append(s.conversations, conversation)
+	for _, id := range memberIds {
+		s.conversationMembers = append(s.conversationMembers, database.ConversationMember{
+			ConversationID: conversation.ID,
+			UserID:         id,
+			JoinedAt:       now,
+		})
+	}
+	return conversation, nil
+}
+
+func (s *Store) CreateMessage(ctx context.Context, arg database.CreateMessageParams) (database.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conversationIndex(arg.ConversationID) < 0 {
+		return database.Message{}, foreignKeyViolation("messages_conversation_id_fkey")
+	}
+	if !s.userExists(arg.SenderID) {
+		return database.Message{}, foreignKeyViolation("messages_sender_id_fkey")
+	}
+	message := database.Message{
+		ID:             uuid.New(),
+		CreatedAt:      s.timestamp(),
+		ConversationID: arg.ConversationID,
+		SenderID:       arg.SenderID,
+		Body:           arg.Body,
+	}
+	s.messages = append(s.messages, message)
+	return message, nil
+}
+
+func (s *Store) DeleteMessageForUser(ctx context.Context, arg database.DeleteMessageForUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exists := slices.ContainsFunc(s.messages, func(m database.Message) bool {
+		return m.ID == arg.ID && m.ConversationID == arg.ConversationID
+	})
+	if !exists || !s.isMember(arg.ConversationID, arg.UserID) || s.messageDeleted(arg.ID, arg.UserID) {
+		return 0, nil
+	}
+	s.messageDeletions = append(s.messageDeletions, database.MessageDeletion{
+		MessageID: arg.ID,
+		UserID:    arg.UserID,
+		DeletedAt: s.timestamp(),
+	})
+	return 1, nil
+}
+
+func (s *Store) FindDirectConversation(ctx context.Context, arg database.FindDirectConversationParams) (database.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conversations := filter(s.conversations, func(c database.Conversation) bool {
+		return s.isMember(c.ID, arg.UserID) && s.isMember(c.ID, arg.OtherUserID) && len(s.memberIDs(c.ID)) == 2
+	})
+	if len(conversations) == 0 {
+		return database.Conversation{}, sql.ErrNoRows
+	}
+	slices.SortStableFunc(conversations, byCreatedAt(func(c database.Conversation) time.Time { return c.CreatedAt }, false))
+	return conversations[0], nil
+}
+
+func (s *Store) IsConversationMember(ctx context.Context, arg database.IsConversationMemberParams) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isMember(arg.ConversationID, arg.UserID), nil
+}
+
+func (s *Store) ListConversations(ctx context.Context, arg database.ListConversationsParams) ([]database.ListConversationsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conversations := filter(s.conversations, func(c database.Conversation) bool { return s.isMember(c.ID, arg.UserID) })
+	slices.SortStableFunc(conversations, func(a, b database.Conversation) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+
+	var rows []database.ListConversationsRow
+	for _, c := range page(conversations, arg.Limit, arg.Offset) {
+		row := database.ListConversationsRow{
+			ID:        c.ID,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			MemberIds: s.memberIDs(c.ID),
+		}
+		lastReadAt := s.conversationMembers[slices.IndexFunc(s.conversationMembers, func(m database.ConversationMember) bool {
+			return m.ConversationID == c.ID && m.UserID == arg.UserID
+		})].LastReadAt
+		for _, m := range s.visibleMessages(c.ID, arg.UserID) {
+			if !row.LastMessageCreatedAt.Valid || m.CreatedAt.After(row.LastMessageCreatedAt.Time) {
+				row.LastMessageID = uuid.NullUUID{UUID: m.ID, Valid: true}
+				row.LastMessageSenderID = uuid.NullUUID{UUID: m.SenderID, Valid: true}
+				row.LastMessageBody = sql.NullString{String: m.Body, Valid: true}
+				row.LastMessageCreatedAt = nullTime(m.CreatedAt)
+			}
+			if m.SenderID != arg.UserID && (!lastReadAt.Valid || m.CreatedAt.After(lastReadAt.Time)) {
+				row.UnreadCount++
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (s *Store) ListMessages(ctx context.Context, arg database.ListMessagesParams) ([]database.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := s.visibleMessages(arg.ConversationID, arg.UserID)
+	slices.SortStableFunc(messages, byCreatedAt(func(m database.Message) time.Time { return m.CreatedAt }, true))
+	return page(messages, arg.Limit, arg.Offset), nil
+}
+
+func (s *Store) MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.timestamp()
+	for i, m := range s.conversationMembers {
+		if m.ConversationID == arg.ConversationID && m.UserID == arg.UserID {
+			s.conversationMembers[i].LastReadAt = nullTime(now)
+		}
+	}
+	return nil
+}
+
+func (s *Store) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.conversationIndex(id); i >= 0 {
+		s.conversations[i].UpdatedAt = s.timestamp()
+	}
+	return nil
+}
+
+func (s *Store) conversationIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.conversations, func(c database.Conversation) bool { return c.ID == id })
+}
+
+// memberIDs returns a conversation's members ordered by when they joined,
+// then by ID.
+func (s *Store) memberIDs(conversationID uuid.UUID) []uuid.UUID {
+	members := filter(s.conversationMembers, func(m database.ConversationMember) bool { return m.ConversationID == conversationID })
+	slices.SortStableFunc(members, func(a, b database.ConversationMember) int {
+		if c := a.JoinedAt.Compare(b.JoinedAt); c != 0 {
+			return c
+		}
+		return compareUUIDs(a.UserID, b.UserID)
+	})
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.UserID)
+	}
+	return ids
+}
+
+func (s *Store) messageDeleted(messageID, userID uuid.UUID) bool {
+	return slices.ContainsFunc(s.messageDeletions, func(d database.MessageDeletion) bool {
+		return d.MessageID == messageID && d.UserID == userID
+	})
+}
+
+// visibleMessages returns the messages in a conversation that userID hasn't
+// deleted for themselves.
+func (s *Store) visibleMessages(conversationID, userID uuid.UUID) []database.Message {
+	return filter(s.messages, func(m database.Message) bool {
+		return m.ConversationID == conversationID && !s.messageDeleted(m.ID, userID)
+	})
+}
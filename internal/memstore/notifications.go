@@ -0,0 +1,171 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, n := range s.visibleNotifications(userID) {
+		if !n.ReadAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CreateNotification inserts nothing, without an error, when the actor is
+// the recipient, the recipient has blocked the actor, or the recipient has
+// turned this type of notification off.
+func (s *Store) CreateNotification(ctx context.Context, arg database.CreateNotificationParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if arg.ActorID.Valid && arg.ActorID.UUID == arg.UserID {
+		return nil
+	}
+	if arg.ActorID.Valid && s.blocked(arg.UserID, arg.ActorID.UUID) {
+		return nil
+	}
+	disabled := slices.ContainsFunc(s.notificationPreferences, func(p database.NotificationPreference) bool {
+		return p.UserID == arg.UserID && p.Type == arg.Type && !p.Enabled
+	})
+	if disabled {
+		return nil
+	}
+	if !s.userExists(arg.UserID) || (arg.ActorID.Valid && !s.userExists(arg.ActorID.UUID)) {
+		return foreignKeyViolation("notifications_user_id_fkey")
+	}
+	if arg.ChirpID.Valid && s.chirpIndex(arg.ChirpID.UUID) < 0 {
+		return foreignKeyViolation("notifications_chirp_id_fkey")
+	}
+	s.notifications = append(s.notifications, database.Notification{
+		ID:        uuid.New(),
+		CreatedAt: s.timestamp(),
+		UserID:    arg.UserID,
+		ActorID:   arg.ActorID,
+		Type:      arg.Type,
+		ChirpID:   arg.ChirpID,
+	})
+	return nil
+}
+
+func (s *Store) ListNotificationGroups(ctx context.Context, arg database.ListNotificationGroupsParams) ([]database.ListNotificationGroupsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	type groupKey struct {
+		Type    string
+		ChirpID uuid.NullUUID
+	}
+	notifications := s.visibleNotifications(arg.UserID)
+	slices.SortStableFunc(notifications, func(a, b database.Notification) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	var groups []database.ListNotificationGroupsRow
+	index := map[groupKey]int{}
+	for _, n := range notifications {
+		key := groupKey{Type: n.Type, ChirpID: n.ChirpID}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, database.ListNotificationGroupsRow{
+				Type:     n.Type,
+				ChirpID:  n.ChirpID,
+				LatestAt: n.CreatedAt,
+			})
+		}
+		g := &groups[i]
+		g.Ids = append(g.Ids, n.ID)
+		if n.ActorID.Valid {
+			g.ActorIds = append(g.ActorIds, n.ActorID.UUID)
+		}
+		if !n.ReadAt.Valid {
+			g.UnreadCount++
+		}
+	}
+	for i := range groups {
+		if groups[i].ActorIds != nil {
+			groups[i].ActorIds = sortedUUIDs(groups[i].ActorIds)
+		}
+	}
+	// Groups were created newest first, so they're already ordered by
+	// latest_at; the sort only makes that explicit.
+	slices.SortStableFunc(groups, func(a, b database.ListNotificationGroupsRow) int { return b.LatestAt.Compare(a.LatestAt) })
+	return page(groups, arg.Limit, arg.Offset), nil
+}
+
+func (s *Store) ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	preferences := filter(s.notificationPreferences, func(p database.NotificationPreference) bool { return p.UserID == userID })
+	slices.SortStableFunc(preferences, func(a, b database.NotificationPreference) int { return cmp.Compare(a.Type, b.Type) })
+	return preferences, nil
+}
+
+func (s *Store) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.timestamp()
+	var count int64
+	for i, n := range s.notifications {
+		if n.UserID == userID && !n.ReadAt.Valid {
+			s.notifications[i].ReadAt = nullTime(now)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) MarkNotificationsRead(ctx context.Context, arg database.MarkNotificationsReadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.timestamp()
+	var count int64
+	for i, n := range s.notifications {
+		if n.UserID == arg.UserID && slices.Contains(arg.Ids, n.ID) && !n.ReadAt.Valid {
+			s.notifications[i].ReadAt = nullTime(now)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) UpsertNotificationPreference(ctx context.Context, arg database.UpsertNotificationPreferenceParams) (database.NotificationPreference, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.userExists(arg.UserID) {
+		return database.NotificationPreference{}, foreignKeyViolation("notification_preferences_user_id_fkey")
+	}
+	preference := database.NotificationPreference{
+		UserID:    arg.UserID,
+		Type:      arg.Type,
+		Enabled:   arg.Enabled,
+		UpdatedAt: s.timestamp(),
+	}
+	i := slices.IndexFunc(s.notificationPreferences, func(p database.NotificationPreference) bool {
+		return p.UserID == arg.UserID && p.Type == arg.Type
+	})
+	if i >= 0 {
+		s.notificationPreferences[i] = preference
+	} else {
+		s.notificationPreferences = append(s.notificationPreferences, preference)
+	}
+	return preference, nil
+}
+
+// visibleNotifications returns a user's notifications, leaving out those
+// from actors they've muted or blocked.
+func (s *Store) visibleNotifications(userID uuid.UUID) []database.Notification {
+	return filter(s.notifications, func(n database.Notification) bool {
+		if n.UserID != userID {
+			return false
+		}
+		return !n.ActorID.Valid || (!s.muted(userID, n.ActorID.UUID) && !s.blocked(userID, n.ActorID.UUID))
+	})
+}
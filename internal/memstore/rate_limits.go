@@ -0,0 +1,53 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+)
+
+func (s *Store) CreateRateLimit(ctx context.Context, arg database.CreateRateLimitParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rateLimitIndex(arg.Key) >= 0 {
+		return nil
+	}
+	s.rateLimits = append(s.rateLimits, database.RateLimit{Key: arg.Key, FullAt: arg.FullAt})
+	return nil
+}
+
+func (s *Store) DeleteExpiredRateLimits(ctx context.Context, fullAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.rateLimits)
+	s.rateLimits = slices.DeleteFunc(s.rateLimits, func(l database.RateLimit) bool { return !l.FullAt.After(fullAt) })
+	return int64(before - len(s.rateLimits)), nil
+}
+
+// GetRateLimitForUpdate needs no row lock; transactions already run one at
+// a time.
+func (s *Store) GetRateLimitForUpdate(ctx context.Context, key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.rateLimitIndex(key)
+	if i < 0 {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return s.rateLimits[i].FullAt, nil
+}
+
+func (s *Store) UpdateRateLimit(ctx context.Context, arg database.UpdateRateLimitParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.rateLimitIndex(arg.Key); i >= 0 {
+		s.rateLimits[i].FullAt = arg.FullAt
+	}
+	return nil
+}
+
+func (s *Store) rateLimitIndex(key string) int {
+	return slices.IndexFunc(s.rateLimits, func(l database.RateLimit) bool { return l.Key == key })
+}
@@ -0,0 +1,79 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refreshTokenIndex(arg.Token) >= 0 {
+		return database.RefreshToken{}, uniqueViolation("refresh_tokens_pkey")
+	}
+	if !s.userExists(arg.UserID) {
+		return database.RefreshToken{}, foreignKeyViolation("refresh_tokens_user_id_fkey")
+	}
+	now := s.timestamp()
+	token := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	s.refreshTokens = append(s.refreshTokens, token)
+	return token, nil
+}
+
+func (s *Store) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.refreshTokenIndex(token)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	t := s.refreshTokens[i]
+	if t.RevokedAt.Valid || !t.ExpiresAt.After(s.timestamp()) {
+		return database.User{}, sql.ErrNoRows
+	}
+	u := s.userIndex(t.UserID)
+	if u < 0 || s.users[u].SuspendedAt.Valid || s.users[u].DeletedAt.Valid {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[u], nil
+}
+
+func (s *Store) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.timestamp()
+	for i, t := range s.refreshTokens {
+		if t.UserID == userID && !t.RevokedAt.Valid {
+			s.refreshTokens[i].RevokedAt = nullTime(now)
+			s.refreshTokens[i].UpdatedAt = now
+		}
+	}
+	return nil
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.refreshTokenIndex(token)
+	if i < 0 {
+		return database.RefreshToken{}, sql.ErrNoRows
+	}
+	now := s.timestamp()
+	s.refreshTokens[i].RevokedAt = nullTime(now)
+	s.refreshTokens[i].UpdatedAt = now
+	return s.refreshTokens[i], nil
+}
+
+func (s *Store) refreshTokenIndex(token string) int {
+	return slices.IndexFunc(s.refreshTokens, func(t database.RefreshToken) bool { return t.Token == token })
+}
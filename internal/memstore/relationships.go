@@ -0,0 +1,109 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) BlockUser(ctx context.Context, arg database.BlockUserParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if arg.BlockerID == arg.BlockedID {
+		return checkViolation("user_blocks_check")
+	}
+	if !s.userExists(arg.BlockerID) || !s.userExists(arg.BlockedID) {
+		return foreignKeyViolation("user_blocks_blocked_id_fkey")
+	}
+	if s.blocked(arg.BlockerID, arg.BlockedID) {
+		return nil
+	}
+	s.userBlocks = append(s.userBlocks, database.UserBlock{
+		BlockerID: arg.BlockerID,
+		BlockedID: arg.BlockedID,
+		CreatedAt: s.timestamp(),
+	})
+	return nil
+}
+
+func (s *Store) HasBlockBetween(ctx context.Context, arg database.HasBlockBetweenParams) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.ContainsFunc(s.userBlocks, func(b database.UserBlock) bool {
+		return (b.BlockerID == arg.UserID && slices.Contains(arg.OtherIds, b.BlockedID)) ||
+			(slices.Contains(arg.OtherIds, b.BlockerID) && b.BlockedID == arg.UserID)
+	}), nil
+}
+
+func (s *Store) HasBlockInConversation(ctx context.Context, arg database.HasBlockInConversationParams) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.conversationMembers {
+		if m.ConversationID != arg.ConversationID {
+			continue
+		}
+		if s.blocked(m.UserID, arg.UserID) || s.blocked(arg.UserID, m.UserID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) ListBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]database.UserBlock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocks := filter(s.userBlocks, func(b database.UserBlock) bool { return b.BlockerID == blockerID })
+	slices.SortStableFunc(blocks, byCreatedAt(func(b database.UserBlock) time.Time { return b.CreatedAt }, true))
+	return blocks, nil
+}
+
+func (s *Store) ListMutedUsers(ctx context.Context, muterID uuid.UUID) ([]database.UserMute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mutes := filter(s.userMutes, func(m database.UserMute) bool { return m.MuterID == muterID })
+	slices.SortStableFunc(mutes, byCreatedAt(func(m database.UserMute) time.Time { return m.CreatedAt }, true))
+	return mutes, nil
+}
+
+func (s *Store) MuteUser(ctx context.Context, arg database.MuteUserParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if arg.MuterID == arg.MutedID {
+		return checkViolation("user_mutes_check")
+	}
+	if !s.userExists(arg.MuterID) || !s.userExists(arg.MutedID) {
+		return foreignKeyViolation("user_mutes_muted_id_fkey")
+	}
+	if s.muted(arg.MuterID, arg.MutedID) {
+		return nil
+	}
+	s.userMutes = append(s.userMutes, database.UserMute{
+		MuterID:   arg.MuterID,
+		MutedID:   arg.MutedID,
+		CreatedAt: s.timestamp(),
+	})
+	return nil
+}
+
+func (s *Store) UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.userBlocks)
+	s.userBlocks = slices.DeleteFunc(s.userBlocks, func(b database.UserBlock) bool {
+		return b.BlockerID == arg.BlockerID && b.BlockedID == arg.BlockedID
+	})
+	return int64(before - len(s.userBlocks)), nil
+}
+
+func (s *Store) UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.userMutes)
+	s.userMutes = slices.DeleteFunc(s.userMutes, func(m database.UserMute) bool {
+		return m.MuterID == arg.MuterID && m.MutedID == arg.MutedID
+	})
+	return int64(before - len(s.userMutes)), nil
+}
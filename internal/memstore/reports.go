@@ -0,0 +1,134 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	reportReasons  = []string{"spam", "harassment", "hate", "violence", "misinformation", "other"}
+	reportStatuses = []string{"open", "resolved", "dismissed"}
+)
+
+func (s *Store) AssignReport(ctx context.Context, arg database.AssignReportParams) (database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.reportIndex(arg.ID)
+	if i < 0 {
+		return database.Report{}, sql.ErrNoRows
+	}
+	if arg.AssigneeID.Valid && !s.userExists(arg.AssigneeID.UUID) {
+		return database.Report{}, foreignKeyViolation("reports_assignee_id_fkey")
+	}
+	s.reports[i].AssigneeID = arg.AssigneeID
+	s.reports[i].UpdatedAt = s.timestamp()
+	return s.reports[i], nil
+}
+
+func (s *Store) CloseReport(ctx context.Context, arg database.CloseReportParams) (database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.reportIndex(arg.ID)
+	if i < 0 || s.reports[i].Status != "open" {
+		return database.Report{}, sql.ErrNoRows
+	}
+	if !slices.Contains(reportStatuses, arg.Status) {
+		return database.Report{}, checkViolation("reports_status_check")
+	}
+	now := s.timestamp()
+	s.reports[i].Status = arg.Status
+	s.reports[i].ResolvedAt = nullTime(now)
+	s.reports[i].UpdatedAt = now
+	return s.reports[i], nil
+}
+
+func (s *Store) CreateModerationAction(ctx context.Context, arg database.CreateModerationActionParams) (database.ModerationAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if arg.ModeratorID.Valid && !s.userExists(arg.ModeratorID.UUID) {
+		return database.ModerationAction{}, foreignKeyViolation("moderation_actions_moderator_id_fkey")
+	}
+	if arg.ReportID.Valid && s.reportIndex(arg.ReportID.UUID) < 0 {
+		return database.ModerationAction{}, foreignKeyViolation("moderation_actions_report_id_fkey")
+	}
+	action := database.ModerationAction{
+		ID:            uuid.New(),
+		CreatedAt:     s.timestamp(),
+		ModeratorID:   arg.ModeratorID,
+		ReportID:      arg.ReportID,
+		Action:        arg.Action,
+		TargetUserID:  arg.TargetUserID,
+		TargetChirpID: arg.TargetChirpID,
+		Note:          arg.Note,
+	}
+	s.moderationActions = append(s.moderationActions, action)
+	return action, nil
+}
+
+func (s *Store) CreateReport(ctx context.Context, arg database.CreateReportParams) (database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(reportReasons, arg.Reason) {
+		return database.Report{}, checkViolation("reports_reason_check")
+	}
+	if !s.userExists(arg.ReporterID) || !s.userExists(arg.ReportedUserID) {
+		return database.Report{}, foreignKeyViolation("reports_reported_user_id_fkey")
+	}
+	if arg.ChirpID.Valid && s.chirpIndex(arg.ChirpID.UUID) < 0 {
+		return database.Report{}, foreignKeyViolation("reports_chirp_id_fkey")
+	}
+	now := s.timestamp()
+	report := database.Report{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ReporterID:     arg.ReporterID,
+		ReportedUserID: arg.ReportedUserID,
+		ChirpID:        arg.ChirpID,
+		Reason:         arg.Reason,
+		Details:        arg.Details,
+		Status:         "open",
+	}
+	s.reports = append(s.reports, report)
+	return report, nil
+}
+
+func (s *Store) GetReport(ctx context.Context, id uuid.UUID) (database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.reportIndex(id)
+	if i < 0 {
+		return database.Report{}, sql.ErrNoRows
+	}
+	return s.reports[i], nil
+}
+
+func (s *Store) ListModerationActions(ctx context.Context, arg database.ListModerationActionsParams) ([]database.ModerationAction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	actions := slices.Clone(s.moderationActions)
+	slices.SortStableFunc(actions, byCreatedAt(func(a database.ModerationAction) time.Time { return a.CreatedAt }, true))
+	return page(actions, arg.Limit, arg.Offset), nil
+}
+
+func (s *Store) ListReports(ctx context.Context, arg database.ListReportsParams) ([]database.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reports := filter(s.reports, func(r database.Report) bool {
+		return (!arg.Status.Valid || r.Status == arg.Status.String) &&
+			(!arg.Reason.Valid || r.Reason == arg.Reason.String) &&
+			(!arg.AssigneeID.Valid || r.AssigneeID == arg.AssigneeID) &&
+			(!arg.Unassigned || !r.AssigneeID.Valid)
+	})
+	slices.SortStableFunc(reports, byCreatedAt(func(r database.Report) time.Time { return r.CreatedAt }, false))
+	return page(reports, arg.PageLimit, arg.PageOffset), nil
+}
+
+func (s *Store) reportIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.reports, func(r database.Report) bool { return r.ID == id })
+}
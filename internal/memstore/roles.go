@@ -0,0 +1,110 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) GetRole(ctx context.Context, name string) (database.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.roles, func(r database.Role) bool { return r.Name == name })
+	if i < 0 {
+		return database.Role{}, sql.ErrNoRows
+	}
+	return s.roles[i], nil
+}
+
+func (s *Store) GrantRole(ctx context.Context, arg database.GrantRoleParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.userExists(arg.UserID) {
+		return 0, foreignKeyViolation("user_roles_user_id_fkey")
+	}
+	if !slices.ContainsFunc(s.roles, func(r database.Role) bool { return r.Name == arg.Role }) {
+		return 0, foreignKeyViolation("user_roles_role_fkey")
+	}
+	if slices.ContainsFunc(s.userRoles, func(r database.UserRole) bool { return r.UserID == arg.UserID && r.Role == arg.Role }) {
+		return 0, nil
+	}
+	s.userRoles = append(s.userRoles, database.UserRole{
+		UserID:    arg.UserID,
+		Role:      arg.Role,
+		GrantedAt: s.timestamp(),
+		GrantedBy: arg.GrantedBy,
+	})
+	return 1, nil
+}
+
+func (s *Store) ListRoles(ctx context.Context) ([]database.ListRolesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.ListRolesRow
+	for _, r := range s.roles {
+		permissions := []string{}
+		for _, p := range s.rolePermissions {
+			if p.Role == r.Name {
+				permissions = append(permissions, p.Permission)
+			}
+		}
+		rows = append(rows, database.ListRolesRow{
+			Name:        r.Name,
+			Description: r.Description,
+			Permissions: sortedStrings(permissions),
+		})
+	}
+	slices.SortFunc(rows, func(a, b database.ListRolesRow) int { return cmp.Compare(a.Name, b.Name) })
+	return rows, nil
+}
+
+func (s *Store) ListUserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var permissions []string
+	for _, p := range s.rolePermissions {
+		if s.hasRole(userID, p.Role) {
+			permissions = append(permissions, p.Permission)
+		}
+	}
+	return sortedStrings(permissions), nil
+}
+
+func (s *Store) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var roles []string
+	for _, r := range s.userRoles {
+		if r.UserID == userID {
+			roles = append(roles, r.Role)
+		}
+	}
+	slices.Sort(roles)
+	return roles, nil
+}
+
+func (s *Store) RevokeRole(ctx context.Context, arg database.RevokeRoleParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.userRoles)
+	s.userRoles = slices.DeleteFunc(s.userRoles, func(r database.UserRole) bool {
+		return r.UserID == arg.UserID && r.Role == arg.Role
+	})
+	return int64(before - len(s.userRoles)), nil
+}
+
+func (s *Store) UserHasPermission(ctx context.Context, arg database.UserHasPermissionParams) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.ContainsFunc(s.rolePermissions, func(p database.RolePermission) bool {
+		return p.Permission == arg.Permission && s.hasRole(arg.UserID, p.Role)
+	}), nil
+}
+
+func (s *Store) hasRole(userID uuid.UUID, role string) bool {
+	return slices.ContainsFunc(s.userRoles, func(r database.UserRole) bool { return r.UserID == userID && r.Role == role })
+}
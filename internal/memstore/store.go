@@ -0,0 +1,300 @@
+// Package memstore is an in-memory implementation of database.Querier for
+// tests and local development. It mirrors the Postgres queries closely
+// enough for handlers to behave the same on either: missing rows are
+// sql.ErrNoRows, and constraint violations are *pq.Error values with the
+// codes Postgres would use. Nothing is persisted.
+package memstore
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Store holds every table in memory.
+type Store struct {
+	mu  sync.Mutex
+	now func() time.Time
+	tables
+}
+
+// tables holds one slice of rows per table. Rows are kept in insertion
+// order, which is also how ties are broken when sorting.
+type tables struct {
+	users                   []database.User
+	chirps                  []database.Chirp
+	refreshTokens           []database.RefreshToken
+	emailVerificationTokens []database.EmailVerificationToken
+	notifications           []database.Notification
+	notificationPreferences []database.NotificationPreference
+	conversations           []database.Conversation
+	conversationMembers     []database.ConversationMember
+	messages                []database.Message
+	messageDeletions        []database.MessageDeletion
+	userBlocks              []database.UserBlock
+	userMutes               []database.UserMute
+	reports                 []database.Report
+	moderationActions       []database.ModerationAction
+	roles                   []database.Role
+	rolePermissions         []database.RolePermission
+	userRoles               []database.UserRole
+	rateLimits              []database.RateLimit
+	idempotencyKeys         []database.IdempotencyKey
+}
+
+// clone copies every table, so changes to the copy don't show through.
+func (t tables) clone() tables {
+	return tables{
+		users:                   slices.Clone(t.users),
+		chirps:                  slices.Clone(t.chirps),
+		refreshTokens:           slices.Clone(t.refreshTokens),
+		emailVerificationTokens: slices.Clone(t.emailVerificationTokens),
+		notifications:           slices.Clone(t.notifications),
+		notificationPreferences: slices.Clone(t.notificationPreferences),
+		conversations:           slices.Clone(t.conversations),
+		conversationMembers:     slices.Clone(t.conversationMembers),
+		messages:                slices.Clone(t.messages),
+		messageDeletions:        slices.Clone(t.messageDeletions),
+		userBlocks:              slices.Clone(t.userBlocks),
+		userMutes:               slices.Clone(t.userMutes),
+		reports:                 slices.Clone(t.reports),
+		moderationActions:       slices.Clone(t.moderationActions),
+		roles:                   slices.Clone(t.roles),
+		rolePermissions:         slices.Clone(t.rolePermissions),
+		userRoles:               slices.Clone(t.userRoles),
+		rateLimits:              slices.Clone(t.rateLimits),
+		idempotencyKeys:         slices.Clone(t.idempotencyKeys),
+	}
+}
+
+var _ database.Querier = (*Store)(nil)
+
+// New returns an empty store seeded with the roles and permissions the
+// migrations create.
+func New() *Store {
+	return &Store{
+		now: time.Now,
+		tables: tables{
+			roles: []database.Role{
+				{Name: "admin", Description: "Full access, including metrics, resets and role management"},
+				{Name: "moderator", Description: "Reviews reports and can remove anyone's chirps"},
+			},
+			rolePermissions: []database.RolePermission{
+				{Role: "admin", Permission: "metrics:read"},
+				{Role: "admin", Permission: "system:reset"},
+				{Role: "admin", Permission: "roles:manage"},
+				{Role: "admin", Permission: "reports:review"},
+				{Role: "admin", Permission: "chirps:delete_any"},
+				{Role: "moderator", Permission: "reports:review"},
+				{Role: "moderator", Permission: "chirps:delete_any"},
+			},
+		},
+	}
+}
+
+// InTx runs fn against a copy of the store and keeps the copy's changes only
+// if fn returns nil. Everyone else waits until fn is done, so transactions
+// are serializable and never need retrying. fn must use the Querier it is
+// given, not s, or it will deadlock.
+func (s *Store) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := &Store{now: s.now, tables: s.tables.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.tables = tx.tables
+	return nil
+}
+
+// timestamp returns the current time at the precision Postgres stores.
+func (s *Store) timestamp() time.Time {
+	return s.now().UTC().Truncate(time.Microsecond)
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: true}
+}
+
+func uniqueViolation(constraint string) error {
+	return &pq.Error{
+		Code:       "23505",
+		Message:    "duplicate key value violates unique constraint \"" + constraint + "\"",
+		Constraint: constraint,
+	}
+}
+
+func foreignKeyViolation(constraint string) error {
+	return &pq.Error{
+		Code:       "23503",
+		Message:    "insert or update violates foreign key constraint \"" + constraint + "\"",
+		Constraint: constraint,
+	}
+}
+
+func checkViolation(constraint string) error {
+	return &pq.Error{
+		Code:       "23514",
+		Message:    "new row violates check constraint \"" + constraint + "\"",
+		Constraint: constraint,
+	}
+}
+
+// page applies LIMIT and OFFSET.
+func page[T any](items []T, limit, offset int32) []T {
+	if offset < 0 || int(offset) >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit >= 0 && int(limit) < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// filter returns the items matching keep, or nil when none do, the same as
+// a generated :many query.
+func filter[T any](items []T, keep func(T) bool) []T {
+	var out []T
+	for _, item := range items {
+		if keep(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func byCreatedAt[T any](createdAt func(T) time.Time, desc bool) func(a, b T) int {
+	return func(a, b T) int {
+		c := createdAt(a).Compare(createdAt(b))
+		if desc {
+			return -c
+		}
+		return c
+	}
+}
+
+func compareUUIDs(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+func sortedUUIDs(ids []uuid.UUID) []uuid.UUID {
+	slices.SortFunc(ids, compareUUIDs)
+	return slices.CompactFunc(ids, func(a, b uuid.UUID) bool { return a == b })
+}
+
+func sortedStrings(values []string) []string {
+	slices.SortFunc(values, cmp.Compare[string])
+	return slices.Compact(values)
+}
+
+func (s *Store) userIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.users, func(u database.User) bool { return u.ID == id })
+}
+
+func (s *Store) userExists(id uuid.UUID) bool {
+	return s.userIndex(id) >= 0
+}
+
+func (s *Store) userDeleted(id uuid.UUID) bool {
+	i := s.userIndex(id)
+	return i >= 0 && s.users[i].DeletedAt.Valid
+}
+
+func (s *Store) chirpIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.chirps, func(c database.Chirp) bool { return c.ID == id })
+}
+
+func (s *Store) blocked(blockerID, blockedID uuid.UUID) bool {
+	return slices.ContainsFunc(s.userBlocks, func(b database.UserBlock) bool {
+		return b.BlockerID == blockerID && b.BlockedID == blockedID
+	})
+}
+
+func (s *Store) muted(muterID, mutedID uuid.UUID) bool {
+	return slices.ContainsFunc(s.userMutes, func(m database.UserMute) bool {
+		return m.MuterID == muterID && m.MutedID == mutedID
+	})
+}
+
+func (s *Store) isMember(conversationID, userID uuid.UUID) bool {
+	return slices.ContainsFunc(s.conversationMembers, func(m database.ConversationMember) bool {
+		return m.ConversationID == conversationID && m.UserID == userID
+	})
+}
+
+// deleteUser removes a user and applies the ON DELETE rules of every table
+// that references users.
+func (s *Store) deleteUser(id uuid.UUID) {
+	s.users = slices.DeleteFunc(s.users, func(u database.User) bool { return u.ID == id })
+
+	// Collect IDs before deleting, since deleting reorders the slices.
+	for _, c := range filter(s.chirps, func(c database.Chirp) bool { return c.UserID == id }) {
+		s.deleteChirp(c.ID)
+	}
+	for _, m := range filter(s.messages, func(m database.Message) bool { return m.SenderID == id }) {
+		s.deleteMessage(m.ID)
+	}
+	for _, r := range filter(s.reports, func(r database.Report) bool { return r.ReporterID == id || r.ReportedUserID == id }) {
+		s.deleteReport(r.ID)
+	}
+
+	s.refreshTokens = slices.DeleteFunc(s.refreshTokens, func(t database.RefreshToken) bool { return t.UserID == id })
+	s.emailVerificationTokens = slices.DeleteFunc(s.emailVerificationTokens, func(t database.EmailVerificationToken) bool { return t.UserID == id })
+	s.notifications = slices.DeleteFunc(s.notifications, func(n database.Notification) bool {
+		return n.UserID == id || n.ActorID == (uuid.NullUUID{UUID: id, Valid: true})
+	})
+	s.notificationPreferences = slices.DeleteFunc(s.notificationPreferences, func(p database.NotificationPreference) bool { return p.UserID == id })
+	s.conversationMembers = slices.DeleteFunc(s.conversationMembers, func(m database.ConversationMember) bool { return m.UserID == id })
+	s.messageDeletions = slices.DeleteFunc(s.messageDeletions, func(d database.MessageDeletion) bool { return d.UserID == id })
+	s.userBlocks = slices.DeleteFunc(s.userBlocks, func(b database.UserBlock) bool { return b.BlockerID == id || b.BlockedID == id })
+	s.userMutes = slices.DeleteFunc(s.userMutes, func(m database.UserMute) bool { return m.MuterID == id || m.MutedID == id })
+	s.userRoles = slices.DeleteFunc(s.userRoles, func(r database.UserRole) bool { return r.UserID == id })
+
+	for i := range s.reports {
+		if s.reports[i].AssigneeID.UUID == id {
+			s.reports[i].AssigneeID = uuid.NullUUID{}
+		}
+	}
+	for i := range s.moderationActions {
+		if s.moderationActions[i].ModeratorID.UUID == id {
+			s.moderationActions[i].ModeratorID = uuid.NullUUID{}
+		}
+	}
+	for i := range s.userRoles {
+		if s.userRoles[i].GrantedBy.UUID == id {
+			s.userRoles[i].GrantedBy = uuid.NullUUID{}
+		}
+	}
+}
+
+func (s *Store) deleteChirp(id uuid.UUID) {
+	s.chirps = slices.DeleteFunc(s.chirps, func(c database.Chirp) bool { return c.ID == id })
+	chirpID := uuid.NullUUID{UUID: id, Valid: true}
+	s.notifications = slices.DeleteFunc(s.notifications, func(n database.Notification) bool { return n.ChirpID == chirpID })
+	for _, r := range filter(s.reports, func(r database.Report) bool { return r.ChirpID == chirpID }) {
+		s.deleteReport(r.ID)
+	}
+}
+
+func (s *Store) deleteMessage(id uuid.UUID) {
+	s.messages = slices.DeleteFunc(s.messages, func(m database.Message) bool { return m.ID == id })
+	s.messageDeletions = slices.DeleteFunc(s.messageDeletions, func(d database.MessageDeletion) bool { return d.MessageID == id })
+}
+
+func (s *Store) deleteReport(id uuid.UUID) {
+	s.reports = slices.DeleteFunc(s.reports, func(r database.Report) bool { return r.ID == id })
+	for i := range s.moderationActions {
+		if s.moderationActions[i].ReportID.UUID == id {
+			s.moderationActions[i].ReportID = uuid.NullUUID{}
+		}
+	}
+}
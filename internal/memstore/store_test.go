@@ -0,0 +1,179 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func createUser(t *testing.T, s *Store, email string) database.User {
+	t.Helper()
+	user, err := s.CreateUser(context.Background(), database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		HashedPassword: "hash",
+		Email:          email,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%q) error = %v", email, err)
+	}
+	return user
+}
+
+func TestCreateUserUniqueEmail(t *testing.T) {
+	s := New()
+	createUser(t, s, "a@example.com")
+
+	_, err := s.CreateUser(context.Background(), database.CreateUserParams{
+		ID:    uuid.New(),
+		Email: "a@example.com",
+	})
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		t.Fatalf("CreateUser() error = %v, want unique violation", err)
+	}
+
+	_, err = s.FindUserByEmail(context.Background(), "missing@example.com")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("FindUserByEmail() error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestRetrieveAllChirpsHidesBlockedAndMuted(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	viewer := createUser(t, s, "viewer@example.com")
+	blocked := createUser(t, s, "blocked@example.com")
+	muted := createUser(t, s, "muted@example.com")
+	friend := createUser(t, s, "friend@example.com")
+
+	for _, u := range []database.User{blocked, muted, friend} {
+		_, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: u.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.BlockUser(ctx, database.BlockUserParams{BlockerID: blocked.ID, BlockedID: viewer.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MuteUser(ctx, database.MuteUserParams{MuterID: viewer.ID, MutedID: muted.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	chirps, err := s.RetrieveAllChirps(ctx, viewer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chirps) != 1 || chirps[0].UserID != friend.ID {
+		t.Errorf("RetrieveAllChirps() = %+v, want only the friend's chirp", chirps)
+	}
+}
+
+func TestDeleteUsersCascades(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	author := createUser(t, s, "author@example.com")
+	reader := createUser(t, s, "reader@example.com")
+	chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: author.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  author.ID,
+		ActorID: uuid.NullUUID{UUID: reader.ID, Valid: true},
+		Type:    "like",
+		ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.users) != 0 || len(s.chirps) != 0 || len(s.notifications) != 0 {
+		t.Errorf("DeleteUsers() left %d users, %d chirps, %d notifications", len(s.users), len(s.chirps), len(s.notifications))
+	}
+	if len(s.roles) == 0 {
+		t.Error("DeleteUsers() removed the seeded roles")
+	}
+}
+
+func TestHardDeleteExpiredUsers(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	expired := createUser(t, s, "expired@example.com")
+	recent := createUser(t, s, "recent@example.com")
+
+	s.now = func() time.Time { return now.AddDate(0, 0, -31) }
+	if err := s.SoftDeleteUser(ctx, expired.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now.AddDate(0, 0, -1) }
+	if err := s.SoftDeleteUser(ctx, recent.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now }
+
+	deleted, err := s.HardDeleteExpiredUsers(ctx, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("HardDeleteExpiredUsers() = %d, want 1", deleted)
+	}
+	if _, err := s.RestoreUser(ctx, database.RestoreUserParams{ID: recent.ID, GraceDays: 30}); err != nil {
+		t.Errorf("RestoreUser() error = %v, want the recently deleted user back", err)
+	}
+}
+
+func TestListNotificationGroups(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	author := createUser(t, s, "author@example.com")
+	chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: author.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		fan := createUser(t, s, email)
+		err := s.CreateNotification(ctx, database.CreateNotificationParams{
+			UserID:  author.ID,
+			ActorID: uuid.NullUUID{UUID: fan.ID, Valid: true},
+			Type:    "like",
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Notifying yourself is a no-op.
+	err = s.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  author.ID,
+		ActorID: uuid.NullUUID{UUID: author.ID, Valid: true},
+		Type:    "like",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := s.ListNotificationGroups(ctx, database.ListNotificationGroupsParams{UserID: author.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("ListNotificationGroups() returned %d groups, want 1", len(groups))
+	}
+	if g := groups[0]; len(g.Ids) != 2 || len(g.ActorIds) != 2 || g.UnreadCount != 2 {
+		t.Errorf("group = %+v, want 2 notifications from 2 actors, both unread", g)
+	}
+}
@@ -0,0 +1,189 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+func (s *Store) CountUsersByIDs(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, u := range s.users {
+		if slices.Contains(ids, u.ID) && !u.DeletedAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userExists(arg.ID) {
+		return database.User{}, uniqueViolation("users_pkey")
+	}
+	if slices.ContainsFunc(s.users, func(u database.User) bool { return u.Email == arg.Email }) {
+		return database.User{}, uniqueViolation("users_email_key")
+	}
+	user := database.User{
+		ID:             arg.ID,
+		CreatedAt:      arg.CreatedAt.UTC().Truncate(time.Microsecond),
+		UpdatedAt:      arg.UpdatedAt.UTC().Truncate(time.Microsecond),
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		IsChirpyRed:    sql.NullBool{Bool: false, Valid: true},
+	}
+	s.users = append(s.users, user)
+	return user, nil
+}
+
+func (s *Store) DeleteUsers(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range slices.Clone(s.users) {
+		s.deleteUser(u.ID)
+	}
+	return nil
+}
+
+func (s *Store) FindUserByEmail(ctx context.Context, email string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.users, func(u database.User) bool { return u.Email == email })
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndex(id)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) GetUserPasswordHash(ctx context.Context, id uuid.UUID) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndex(id)
+	if i < 0 {
+		return "", sql.ErrNoRows
+	}
+	return s.users[i].HashedPassword, nil
+}
+
+func (s *Store) HardDeleteExpiredUsers(ctx context.Context, graceDays int32) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := s.timestamp().AddDate(0, 0, -int(graceDays))
+	expired := filter(s.users, func(u database.User) bool {
+		return u.DeletedAt.Valid && u.DeletedAt.Time.Before(cutoff)
+	})
+	for _, u := range expired {
+		s.deleteUser(u.ID)
+	}
+	return int64(len(expired)), nil
+}
+
+func (s *Store) ListRecentUsers(ctx context.Context, arg database.ListRecentUsersParams) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := filter(s.users, func(u database.User) bool { return !u.CreatedAt.Before(arg.Since) })
+	slices.SortStableFunc(users, byCreatedAt(func(u database.User) time.Time { return u.CreatedAt }, true))
+	return page(users, arg.PageLimit, 0), nil
+}
+
+func (s *Store) MarkEmailVerified(ctx context.Context, arg database.MarkEmailVerifiedParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndex(arg.ID)
+	if i < 0 || s.users[i].Email != arg.Email {
+		return 0, nil
+	}
+	now := s.timestamp()
+	s.users[i].EmailVerifiedAt = nullTime(now)
+	s.users[i].UpdatedAt = now
+	return 1, nil
+}
+
+func (s *Store) RestoreUser(ctx context.Context, arg database.RestoreUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.timestamp()
+	i := s.userIndex(arg.ID)
+	if i < 0 || !s.users[i].DeletedAt.Valid || !s.users[i].DeletedAt.Time.After(now.AddDate(0, 0, -int(arg.GraceDays))) {
+		return database.User{}, sql.ErrNoRows
+	}
+	s.users[i].DeletedAt = sql.NullTime{}
+	s.users[i].UpdatedAt = now
+	return s.users[i], nil
+}
+
+func (s *Store) SoftDeleteUser(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.userIndex(id); i >= 0 {
+		now := s.timestamp()
+		s.users[i].DeletedAt = nullTime(now)
+		s.users[i].UpdatedAt = now
+	}
+	return nil
+}
+
+func (s *Store) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.userIndex(id); i >= 0 {
+		now := s.timestamp()
+		s.users[i].SuspendedAt = nullTime(now)
+		s.users[i].UpdatedAt = now
+	}
+	return nil
+}
+
+func (s *Store) UpdateUserEmail(ctx context.Context, arg database.UpdateUserEmailParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndex(arg.ID)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	if slices.ContainsFunc(s.users, func(u database.User) bool { return u.Email == arg.Email && u.ID != arg.ID }) {
+		return database.User{}, uniqueViolation("users_email_key")
+	}
+	s.users[i].Email = arg.Email
+	s.users[i].EmailVerifiedAt = sql.NullTime{}
+	s.users[i].UpdatedAt = s.timestamp()
+	return s.users[i], nil
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndex(arg.ID)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	s.users[i].HashedPassword = arg.HashedPassword
+	s.users[i].UpdatedAt = s.timestamp()
+	return s.users[i], nil
+}
+
+func (s *Store) UpgradeToChirpyRed(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.userIndex(id); i >= 0 {
+		s.users[i].IsChirpyRed = sql.NullBool{Bool: true, Valid: true}
+	}
+	return nil
+}
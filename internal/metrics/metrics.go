@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "chirpy"
+
+// Metrics holds every collector Chirpy exports, registered on its own
+// registry so /metrics and the admin dashboard read exactly the same data.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	RequestsTotal    *prometheus.CounterVec
+	RequestDuration  *prometheus.HistogramVec
+	RequestsInFlight prometheus.Gauge
+	ResponseSize     *prometheus.HistogramVec
+
+	// FileserverHits has no labels; it is a vector only so the dev reset
+	// endpoint can zero it with Reset.
+	FileserverHits *prometheus.CounterVec
+	ChirpsCreated  prometheus.Counter
+	Logins         *prometheus.CounterVec
+	WebhookEvents  *prometheus.CounterVec
+	RateLimited    *prometheus.CounterVec
+}
+
+// Label values for Logins.
+const (
+	LoginSucceeded = "succeeded"
+	LoginFailed    = "failed"
+)
+
+// New creates the collectors and registers them, along with Go runtime,
+// process and database pool stats for db.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		RequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		RequestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		}),
+		ResponseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of HTTP response bodies, by method and route pattern.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+		}, []string{"method", "route"}),
+		FileserverHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fileserver_hits_total",
+			Help:      "Requests to the /app file server since startup or the last reset.",
+		}, nil),
+		ChirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_created_total",
+			Help:      "Chirps created.",
+		}),
+		Logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts, by result.",
+		}, []string{"result"}),
+		WebhookEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_events_total",
+			Help:      "Polka webhook events received, by event type.",
+		}, []string{"event"}),
+		RateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_requests_total",
+			Help:      "Requests refused with 429 Too Many Requests, by rate limit policy.",
+		}, []string{"policy"}),
+	}
+
+	m.Registry.MustRegister(
+		m.RequestsTotal,
+		m.RequestDuration,
+		m.RequestsInFlight,
+		m.ResponseSize,
+		m.FileserverHits,
+		m.ChirpsCreated,
+		m.Logins,
+		m.WebhookEvents,
+		m.RateLimited,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		m.Registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+	}
+
+	// Start the labelled counters at zero so they show up before the first
+	// event.
+	m.FileserverHits.WithLabelValues()
+	m.Logins.WithLabelValues(LoginSucceeded)
+	m.Logins.WithLabelValues(LoginFailed)
+	return m
+}
+
+// Snapshot gathers the registry into a flat map. Each family's total over all
+// of its series is keyed by the metric name, and each labelled series by its
+// name and labels as they appear in the text format, e.g.
+// chirpy_logins_total{result="failed"}. Histograms report their sample count.
+func (m *Metrics) Snapshot() (map[string]float64, error) {
+	families, err := m.Registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64, len(families))
+	for _, family := range families {
+		var total float64
+		for _, metric := range family.GetMetric() {
+			var value float64
+			switch {
+			case metric.Counter != nil:
+				value = metric.Counter.GetValue()
+			case metric.Gauge != nil:
+				value = metric.Gauge.GetValue()
+			case metric.Untyped != nil:
+				value = metric.Untyped.GetValue()
+			case metric.Histogram != nil:
+				value = float64(metric.Histogram.GetSampleCount())
+			}
+			total += value
+
+			if len(metric.GetLabel()) > 0 {
+				labels := make([]string, 0, len(metric.GetLabel()))
+				for _, label := range metric.GetLabel() {
+					labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+				}
+				values[family.GetName()+"{"+strings.Join(labels, ",")+"}"] = value
+			}
+		}
+		values[family.GetName()] = total
+	}
+	return values, nil
+}
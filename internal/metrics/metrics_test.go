@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	m := New(nil)
+	m.ChirpsCreated.Inc()
+	m.ChirpsCreated.Inc()
+	m.Logins.WithLabelValues(LoginSucceeded).Inc()
+	m.Logins.WithLabelValues(LoginFailed).Add(3)
+	m.RequestDuration.WithLabelValues("GET", "GET /api/chirps").Observe(0.1)
+	m.FileserverHits.WithLabelValues().Inc()
+	m.FileserverHits.Reset()
+
+	values, err := m.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	tests := map[string]float64{
+		"chirpy_chirps_created_total":             2,
+		"chirpy_logins_total":                     4,
+		`chirpy_logins_total{result="succeeded"}`: 1,
+		`chirpy_logins_total{result="failed"}`:    3,
+		"chirpy_http_request_duration_seconds":    1,
+		"chirpy_fileserver_hits_total":            0,
+		"chirpy_http_requests_in_flight":          0,
+	}
+	for name, want := range tests {
+		if got := values[name]; got != want {
+			t.Errorf("Snapshot()[%s] = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package migrations applies the goose migrations embedded in sql/schema.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dandytron/chirpy.git/sql/schema"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// Commands lists the subcommands Run accepts.
+var Commands = []string{"up", "down", "status", "redo"}
+
+// NewProvider returns a goose provider for the embedded migrations. Every
+// run holds a Postgres advisory lock for its whole duration, so replicas
+// starting at the same time apply migrations one after another instead of
+// racing.
+func NewProvider(db *sql.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, db, schema.FS,
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+}
+
+// Up applies every pending migration and returns how many were applied.
+func Up(ctx context.Context, db *sql.DB) (int, error) {
+	provider, err := NewProvider(db)
+	if err != nil {
+		return 0, err
+	}
+	results, err := provider.Up(ctx)
+	return len(results), err
+}
+
+// Run executes one of Commands, writing a report of what it did to w.
+func Run(ctx context.Context, db *sql.DB, w io.Writer, command string) error {
+	provider, err := NewProvider(db)
+	if err != nil {
+		return err
+	}
+
+	var results []*goose.MigrationResult
+	switch command {
+	case "up":
+		results, err = provider.Up(ctx)
+		if err == nil && len(results) == 0 {
+			fmt.Fprintln(w, "No pending migrations")
+		}
+	case "down":
+		var result *goose.MigrationResult
+		result, err = provider.Down(ctx)
+		if result != nil {
+			results = append(results, result)
+		}
+	case "redo":
+		var down, up *goose.MigrationResult
+		down, err = provider.Down(ctx)
+		if down != nil {
+			results = append(results, down)
+		}
+		if err == nil {
+			up, err = provider.UpByOne(ctx)
+			if up != nil {
+				results = append(results, up)
+			}
+		}
+	case "status":
+		return writeStatus(ctx, provider, w)
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+
+	for _, result := range results {
+		fmt.Fprintln(w, result)
+	}
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		return errors.New("no migration to apply or roll back")
+	}
+	return err
+}
+
+func writeStatus(ctx context.Context, provider *goose.Provider, w io.Writer) error {
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tMIGRATION")
+	for _, status := range statuses {
+		appliedAt := "-"
+		if status.State == goose.StateApplied {
+			appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/dandytron/chirpy.git/sql/schema"
+	_ "github.com/lib/pq"
+)
+
+func TestNewProviderFindsEmbeddedMigrations(t *testing.T) {
+	// sql.Open doesn't connect, so no database is needed to list sources.
+	db, err := sql.Open("postgres", "postgres://localhost/chirpy_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	provider, err := NewProvider(db)
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	sources := provider.ListSources()
+	if len(sources) == 0 {
+		t.Fatal("no embedded migrations found")
+	}
+
+	latest, err := schema.LatestVersion()
+	if err != nil {
+		t.Fatalf("LatestVersion() error = %v", err)
+	}
+	if got := sources[len(sources)-1].Version; got != latest {
+		t.Errorf("last migration version = %d, LatestVersion() = %d", got, latest)
+	}
+}
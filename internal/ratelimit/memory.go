@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in this process, so each instance of a
+// multi-instance deployment enforces its own limits.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]time.Time{}}
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, p Policy, now time.Time) (Result, error) {
+	if !p.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fullAt, result := p.take(m.buckets[key], now)
+	m.buckets[key] = fullAt
+	return result, nil
+}
+
+func (m *MemoryStore) Sweep(ctx context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted int64
+	for key, fullAt := range m.buckets {
+		if !fullAt.After(now) {
+			delete(m.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
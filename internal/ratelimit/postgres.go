@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/tracing"
+)
+
+// PostgresStore keeps buckets in the rate_limits table, so every instance
+// sharing the database enforces the same limits.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Take locks the bucket's row for the read-modify-write, so concurrent
+// requests for the same key queue up instead of both spending the last
+// token. It runs at the default isolation level: under serializable, the
+// queued requests would fail instead of waiting.
+func (p *PostgresStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	if !policy.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+	// The column has microsecond precision.
+	now = now.UTC().Truncate(time.Microsecond)
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+	q := database.New(tracing.WrapDB(tx))
+
+	err = q.CreateRateLimit(ctx, database.CreateRateLimitParams{Key: key, FullAt: now})
+	if err != nil {
+		return Result{}, err
+	}
+	fullAt, err := q.GetRateLimitForUpdate(ctx, key)
+	if err != nil {
+		return Result{}, err
+	}
+	fullAt, result := policy.take(fullAt, now)
+	err = q.UpdateRateLimit(ctx, database.UpdateRateLimitParams{Key: key, FullAt: fullAt})
+	if err != nil {
+		return Result{}, err
+	}
+	return result, tx.Commit()
+}
+
+func (p *PostgresStore) Sweep(ctx context.Context, now time.Time) (int64, error) {
+	return database.New(tracing.WrapDB(p.db)).DeleteExpiredRateLimits(ctx, now.UTC())
+}
@@ -0,0 +1,101 @@
+// Package ratelimit implements token bucket rate limiting with pluggable
+// storage, so limits can live in one process's memory or be shared by every
+// instance through Postgres.
+//
+// A bucket holds Limit tokens and refills one every Period/Limit. Instead of
+// counting tokens, a bucket is stored as the time it will next be full: each
+// request pushes that time back by one refill interval, and a request is
+// refused if it would push it more than Period into the future. Storing one
+// timestamp per key keeps the Postgres store to a single column.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows Limit requests per Period for each key, in bursts of up to
+// Limit. The zero Policy allows everything.
+type Policy struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParsePolicy parses a policy written as "<limit>/<period>", such as
+// "10/1m". "0" disables limiting.
+func ParsePolicy(s string) (Policy, error) {
+	if s == "0" {
+		return Policy{}, nil
+	}
+	limit, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate %q must look like 10/1m", s)
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("rate %q must allow a positive number of requests", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("rate %q must have a positive period", s)
+	}
+	return Policy{Limit: n, Period: d}, nil
+}
+
+// Enabled reports whether the policy limits anything.
+func (p Policy) Enabled() bool {
+	return p.Limit > 0 && p.Period > 0
+}
+
+func (p Policy) String() string {
+	if !p.Enabled() {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", p.Limit, p.Period)
+}
+
+func (p Policy) interval() time.Duration {
+	return p.Period / time.Duration(p.Limit)
+}
+
+// Result describes a bucket after a request was counted against it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a refused request would be allowed.
+	RetryAfter time.Duration
+}
+
+// take spends a token from a bucket that is full at fullAt and returns when
+// it will be full afterwards.
+func (p Policy) take(fullAt, now time.Time) (time.Time, Result) {
+	if fullAt.Before(now) {
+		fullAt = now
+	}
+	next := fullAt.Add(p.interval())
+	result := Result{Limit: p.Limit}
+	if next.Sub(now) > p.Period {
+		result.Reset = fullAt.Sub(now)
+		result.RetryAfter = next.Sub(now) - p.Period
+		return fullAt, result
+	}
+	result.Allowed = true
+	result.Reset = next.Sub(now)
+	result.Remaining = int((p.Period - result.Reset) / p.interval())
+	return next, result
+}
+
+// Store keeps the buckets. Take counts a request against key's bucket under
+// policy p; a disabled policy allows every request.
+type Store interface {
+	Take(ctx context.Context, key string, p Policy, now time.Time) (Result, error)
+	// Sweep forgets buckets that are full again, which is the same as
+	// never having been used.
+	Sweep(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	policy := Policy{Limit: 2, Period: time.Second}
+	now := time.Now()
+
+	for i, wantRemaining := range []int{1, 0} {
+		result, err := store.Take(ctx, "key", policy, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != wantRemaining {
+			t.Errorf("request %d = %+v, want allowed with %d remaining", i+1, result, wantRemaining)
+		}
+	}
+
+	result, _ := store.Take(ctx, "key", policy, now)
+	if result.Allowed || result.RetryAfter != 500*time.Millisecond || result.Reset != time.Second {
+		t.Errorf("third request = %+v, want refused until the next token in 500ms", result)
+	}
+	result, _ = store.Take(ctx, "other", policy, now)
+	if !result.Allowed {
+		t.Error("another key shares the bucket")
+	}
+
+	// One token has come back after the refill interval.
+	result, _ = store.Take(ctx, "key", policy, now.Add(500*time.Millisecond))
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("request after refill = %+v, want allowed with 0 remaining", result)
+	}
+
+	deleted, err := store.Sweep(ctx, now.Add(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 || len(store.buckets) != 0 {
+		t.Errorf("Sweep() = %d, left %d buckets; want both full buckets removed", deleted, len(store.buckets))
+	}
+}
+
+func TestDisabledPolicy(t *testing.T) {
+	result, err := NewMemoryStore().Take(context.Background(), "key", Policy{}, time.Now())
+	if err != nil || !result.Allowed {
+		t.Errorf("Take() = %+v, %v; want a disabled policy to allow everything", result, err)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("10/1m")
+	if err != nil || p != (Policy{Limit: 10, Period: time.Minute}) {
+		t.Errorf("ParsePolicy(10/1m) = %v, %v", p, err)
+	}
+	p, err = ParsePolicy("0")
+	if err != nil || p.Enabled() {
+		t.Errorf("ParsePolicy(0) = %v, %v; want disabled", p, err)
+	}
+	for _, bad := range []string{"10", "x/1m", "-1/1m", "10/soon", "10/0s"} {
+		if _, err := ParsePolicy(bad); err == nil {
+			t.Errorf("ParsePolicy(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct", "203.0.113.9:5000", "", "203.0.113.9"},
+		{"spoofed header from an untrusted peer", "203.0.113.9:5000", "198.51.100.1", "203.0.113.9"},
+		{"one trusted proxy", "10.0.0.1:5000", "198.51.100.1", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:5000", "198.51.100.1, 10.0.0.2", "198.51.100.1"},
+		{"client-supplied prefix is ignored", "10.0.0.1:5000", "192.0.2.66, 198.51.100.1", "198.51.100.1"},
+		{"trusted proxy without a header", "10.0.0.1:5000", "", "10.0.0.1"},
+		{"IPv4-mapped IPv6", "[::ffff:203.0.113.9]:5000", "", "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ClientIP(r, trusted); got.String() != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
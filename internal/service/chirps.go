@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
+)
+
+var profaneWords = map[string]struct{}{
+	"kerfuffle": {},
+	"sharbert":  {},
+	"fornax":    {},
+}
+
+// CreateChirp checks and scrubs body and posts it as userID. A body that is
+// empty or too long fails with a *validation.Error.
+func (s *Service) CreateChirp(ctx context.Context, userID uuid.UUID, body string) (database.Chirp, error) {
+	v := validation.Validator{}
+	v.Required("body", body)
+	v.MaxLength("body", body, s.opts.MaxChirpLength)
+	if err := v.Err(); err != nil {
+		return database.Chirp{}, err
+	}
+
+	return s.store.CreateChirp(ctx, database.CreateChirpParams{
+		Body:   chirpScrubber(body),
+		UserID: userID,
+	})
+}
+
+// DeleteChirp deletes a chirp for its author, or for anyone allowed to
+// delete any chirp. Removing someone else's chirp goes on the moderation
+// audit trail. If unchanged isn't nil, the chirp is only deleted if it
+// reports the chirp as the version the caller last saw, and otherwise
+// DeleteChirp fails with ErrChangedSince.
+func (s *Service) DeleteChirp(ctx context.Context, userID uuid.UUID, access auth.Access, chirpID uuid.UUID, unchanged func(database.Chirp) bool) error {
+	return s.inTx(ctx, func(q database.Querier) error {
+		chirp, err := q.RetrieveSingleChirp(ctx, chirpID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		isAuthor := userID == chirp.UserID
+		if !isAuthor && !access.HasPermission(auth.PermissionChirpsDeleteAny) {
+			return ErrForbidden
+		}
+		if unchanged != nil && !unchanged(chirp) {
+			return ErrChangedSince
+		}
+
+		err = q.DeleteChirps(ctx, chirp.ID)
+		if err != nil {
+			return err
+		}
+		if isAuthor {
+			return nil
+		}
+		_, err = q.CreateModerationAction(ctx, database.CreateModerationActionParams{
+			ModeratorID:   uuid.NullUUID{UUID: userID, Valid: true},
+			Action:        ActionDeleteChirp,
+			TargetUserID:  uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+			TargetChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		})
+		return err
+	})
+}
+
+// Assuming the length validation passed, replace any of the following words in the Chirp with the static 4-character string ****
+func chirpScrubber(chirp string) string {
+	split_chirp := strings.Split(chirp, " ")
+	for i, word := range split_chirp {
+		loweredWord := strings.ToLower(word)
+		if _, ok := profaneWords[loweredWord]; ok {
+			split_chirp[i] = "****"
+		}
+	}
+	return strings.Join(split_chirp, " ")
+}
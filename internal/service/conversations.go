@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// StartConversation starts a conversation between userID and others, who
+// must all exist (ErrNotFound) and have no block with userID
+// (ErrForbidden). A one-to-one conversation that already exists is returned
+// instead, with created false. Looking for it and creating it share a
+// transaction, so two requests racing to start the same direct conversation
+// can't both create one: Postgres aborts one of them, and its retry finds
+// the other's.
+func (s *Service) StartConversation(ctx context.Context, userID uuid.UUID, others []uuid.UUID) (conversation database.Conversation, created bool, err error) {
+	err = s.inTx(ctx, func(q database.Querier) error {
+		found, err := q.CountUsersByIDs(ctx, others)
+		if err != nil {
+			return err
+		}
+		if found != int64(len(others)) {
+			return ErrNotFound
+		}
+
+		blocked, err := q.HasBlockBetween(ctx, database.HasBlockBetweenParams{
+			UserID:   userID,
+			OtherIds: others,
+		})
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrForbidden
+		}
+
+		if len(others) == 1 {
+			conversation, err = q.FindDirectConversation(ctx, database.FindDirectConversationParams{
+				UserID:      userID,
+				OtherUserID: others[0],
+			})
+			if err == nil {
+				created = false
+				return nil
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+
+		conversation, err = q.CreateConversation(ctx, append([]uuid.UUID{userID}, others...))
+		created = err == nil
+		return err
+	})
+	return conversation, created, err
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// Moderation actions that close a report, plus the ones recorded when a
+// moderator deletes a chirp directly.
+const (
+	ActionHideChirp   = "hide_chirp"
+	ActionSuspendUser = "suspend_user"
+	ActionDismiss     = "dismiss"
+	ActionDeleteChirp = "delete_chirp"
+)
+
+// ResolveReport takes a moderator action on an open report and closes it.
+// hide_chirp and suspend_user resolve the report; dismiss closes it with no
+// further effect. The action, the report and the audit trail change
+// together or not at all.
+func (s *Service) ResolveReport(ctx context.Context, moderatorID, reportID uuid.UUID, action, note string) (database.Report, error) {
+	var closed database.Report
+	err := s.inTx(ctx, func(q database.Querier) error {
+		report, err := q.GetReport(ctx, reportID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if report.Status != "open" {
+			return ErrReportClosed
+		}
+
+		record := database.CreateModerationActionParams{
+			ModeratorID:  uuid.NullUUID{UUID: moderatorID, Valid: true},
+			ReportID:     uuid.NullUUID{UUID: report.ID, Valid: true},
+			Action:       action,
+			TargetUserID: uuid.NullUUID{UUID: report.ReportedUserID, Valid: true},
+			Note:         note,
+		}
+		status := "resolved"
+
+		switch action {
+		case ActionHideChirp:
+			if !report.ChirpID.Valid {
+				return ErrNotChirpReport
+			}
+			err = q.HideChirp(ctx, report.ChirpID.UUID)
+			if err != nil {
+				return err
+			}
+			record.TargetChirpID = report.ChirpID
+		case ActionSuspendUser:
+			err = q.SuspendUser(ctx, report.ReportedUserID)
+			if err != nil {
+				return err
+			}
+			// Suspended users can't log in, and they shouldn't be able to
+			// refresh their way back in either.
+			err = q.RevokeAllRefreshTokensForUser(ctx, report.ReportedUserID)
+			if err != nil {
+				return err
+			}
+		case ActionDismiss:
+			status = "dismissed"
+		default:
+			return fmt.Errorf("unknown moderation action %q", action)
+		}
+
+		closed, err = q.CloseReport(ctx, database.CloseReportParams{
+			ID:     report.ID,
+			Status: status,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = q.CreateModerationAction(ctx, record)
+		return err
+	})
+	return closed, err
+}
+
+// ModerateChirp deletes or hides a chirp for an operator who isn't signed in
+// as a user, such as someone running chirpy admin, and records it on the
+// moderation audit trail with no moderator and operator as the note.
+func (s *Service) ModerateChirp(ctx context.Context, chirpID uuid.UUID, action, operator string) error {
+	return s.inTx(ctx, func(q database.Querier) error {
+		chirp, err := q.RetrieveSingleChirp(ctx, chirpID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		switch action {
+		case ActionDeleteChirp:
+			err = q.DeleteChirps(ctx, chirp.ID)
+		case ActionHideChirp:
+			err = q.HideChirp(ctx, chirp.ID)
+		default:
+			return fmt.Errorf("unknown chirp moderation action %q", action)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = q.CreateModerationAction(ctx, database.CreateModerationActionParams{
+			Action:        action,
+			TargetUserID:  uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+			TargetChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Note:          operator,
+		})
+		return err
+	})
+}
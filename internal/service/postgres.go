@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/tracing"
+)
+
+// postgresStore runs traced sqlc queries against a connection pool.
+type postgresStore struct {
+	*database.Queries
+	db *sql.DB
+}
+
+// Postgres returns a Store backed by db. Transactions run at the
+// serializable isolation level.
+func Postgres(db *sql.DB) Store {
+	return &postgresStore{
+		Queries: database.New(tracing.WrapDB(db)),
+		db:      db,
+	}
+}
+
+func (p *postgresStore) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	if err := fn(database.New(tracing.WrapDB(tx))); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
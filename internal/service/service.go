@@ -0,0 +1,93 @@
+// Package service holds Chirpy's business rules, between the HTTP handlers
+// and the sqlc queries. Every operation that writes more than once runs in a
+// single transaction, retried when Postgres can't serialize it, so a failure
+// halfway through never leaves a half-finished change behind.
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/lib/pq"
+)
+
+// Errors callers are expected to tell apart. Anything else is a storage
+// failure.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrForbidden          = errors.New("forbidden")
+	ErrInvalidCredentials = errors.New("incorrect email or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrSuspended          = errors.New("account is suspended")
+	ErrEmailTaken         = errors.New("email is already in use")
+	ErrReportClosed       = errors.New("report is already closed")
+	ErrNotChirpReport     = errors.New("report isn't about a chirp")
+	ErrChangedSince       = errors.New("changed since it was last read")
+	ErrAlreadyVerified    = errors.New("email is already verified")
+)
+
+// Store is what the services run against: the queries, plus a way to run
+// several of them atomically.
+type Store interface {
+	database.Querier
+
+	// InTx runs fn in a transaction, committing if fn returns nil and
+	// rolling back otherwise.
+	InTx(ctx context.Context, fn func(q database.Querier) error) error
+}
+
+// Options are the settings business rules depend on.
+type Options struct {
+	MaxChirpLength  int
+	RefreshTokenTTL time.Duration
+	// AdminEmail is granted the admin role once a user verifies it.
+	AdminEmail string
+}
+
+// Service runs Chirpy's operations against a Store.
+type Service struct {
+	store Store
+	opts  Options
+}
+
+func New(store Store, opts Options) *Service {
+	return &Service{store: store, opts: opts}
+}
+
+const (
+	maxTxAttempts = 3
+	txRetryDelay  = 10 * time.Millisecond
+)
+
+// inTx runs fn in a transaction, retrying it from the start when Postgres
+// aborts it with a serialization failure or a deadlock. fn may run more
+// than once, so it must not have side effects outside the transaction.
+func (s *Service) inTx(ctx context.Context, fn func(q database.Querier) error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.store.InTx(ctx, fn)
+		if err == nil || !isRetryable(err) || attempt == maxTxAttempts {
+			return err
+		}
+		slog.DebugContext(ctx, "Retrying transaction", "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * txRetryDelay):
+		}
+	}
+}
+
+// isRetryable reports whether err is a serialization failure or deadlock,
+// which Postgres expects the client to retry.
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/memstore"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// flakyStore fails its first failures transactions the way Postgres does
+// when it can't serialize them.
+type flakyStore struct {
+	*memstore.Store
+	failures int
+	attempts int
+}
+
+func (f *flakyStore) InTx(ctx context.Context, fn func(q database.Querier) error) error {
+	f.attempts++
+	if f.attempts <= f.failures {
+		return &pq.Error{Code: "40001", Message: "could not serialize access due to concurrent update"}
+	}
+	return f.Store.InTx(ctx, fn)
+}
+
+func newTestService(store Store) *Service {
+	return New(store, Options{
+		MaxChirpLength:  140,
+		RefreshTokenTTL: time.Hour,
+		AdminEmail:      "admin@example.com",
+	})
+}
+
+func TestInTxRetriesSerializationFailures(t *testing.T) {
+	ctx := context.Background()
+	store := &flakyStore{Store: memstore.New(), failures: maxTxAttempts - 1}
+	s := newTestService(store)
+
+	_, err := s.CreateUser(ctx, "retry@example.com", "password")
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v, want success after retrying", err)
+	}
+	if store.attempts != maxTxAttempts {
+		t.Errorf("attempts = %d, want %d", store.attempts, maxTxAttempts)
+	}
+
+	store = &flakyStore{Store: memstore.New(), failures: maxTxAttempts}
+	s = newTestService(store)
+	_, err = s.CreateUser(ctx, "retry@example.com", "password")
+	if !isRetryable(err) {
+		t.Errorf("CreateUser() error = %v, want the serialization failure once retries run out", err)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	s := newTestService(store)
+
+	signup, err := s.CreateUser(ctx, "admin@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signup.VerificationToken == "" {
+		t.Error("CreateUser() issued no verification token")
+	}
+	roles, err := store.ListUserRoles(ctx, signup.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 0 {
+		t.Errorf("roles = %v, want none until the admin email is verified", roles)
+	}
+	granted, err := s.BootstrapAdmin(ctx, signup.User)
+	if err != nil || granted {
+		t.Errorf("BootstrapAdmin() for an unverified user = %v, %v, want nothing granted", granted, err)
+	}
+
+	if err := s.VerifyEmail(ctx, signup.VerificationToken); err != nil {
+		t.Fatal(err)
+	}
+	roles, err = store.ListUserRoles(ctx, signup.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 1 || roles[0] != RoleAdmin {
+		t.Errorf("roles = %v, want the verified admin email to be made an admin", roles)
+	}
+
+	_, err = s.CreateUser(ctx, "admin@example.com", "password")
+	if !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("CreateUser() error = %v, want ErrEmailTaken", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(memstore.New())
+	signup, err := s.CreateUser(ctx, "user@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Login(ctx, "user@example.com", "wrong")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() with the wrong password error = %v, want ErrInvalidCredentials", err)
+	}
+	_, err = s.Login(ctx, "nobody@example.com", "password")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() for an unknown user error = %v, want ErrInvalidCredentials", err)
+	}
+
+	_, err = s.DeleteAccount(ctx, signup.User.ID, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := s.Login(ctx, "user@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.User.DeletedAt.Valid || session.RefreshToken == "" {
+		t.Errorf("Login() = %+v, want a restored user with a refresh token", session)
+	}
+}
+
+func TestCreateChirp(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(memstore.New())
+	signup, err := s.CreateUser(ctx, "user@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chirp, err := s.CreateChirp(ctx, signup.User.ID, "what a Kerfuffle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chirp.Body != "what a ****" {
+		t.Errorf("body = %q, want profanity scrubbed", chirp.Body)
+	}
+
+	for _, body := range []string{"", strings.Repeat("a", 141), strings.Repeat("é", 141)} {
+		_, err = s.CreateChirp(ctx, signup.User.ID, body)
+		var vErr *validation.Error
+		if !errors.As(err, &vErr) {
+			t.Errorf("CreateChirp(%d characters) error = %v, want a validation error", utf8.RuneCountInString(body), err)
+		}
+	}
+	// Length is counted in characters, not bytes.
+	if _, err := s.CreateChirp(ctx, signup.User.ID, strings.Repeat("é", 140)); err != nil {
+		t.Errorf("CreateChirp(140 two-byte characters) error = %v", err)
+	}
+}
+
+func TestDeleteChirp(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	s := newTestService(store)
+	author, err := s.CreateUser(ctx, "author@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.CreateUser(ctx, "other@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirp, err := s.CreateChirp(ctx, author.User.ID, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.DeleteChirp(ctx, other.User.ID, auth.Access{}, chirp.ID, nil)
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatalf("DeleteChirp() by another user error = %v, want ErrForbidden", err)
+	}
+
+	moderator := auth.Access{Permissions: []string{auth.PermissionChirpsDeleteAny}}
+	stale := func(database.Chirp) bool { return false }
+	err = s.DeleteChirp(ctx, other.User.ID, moderator, chirp.ID, stale)
+	if !errors.Is(err, ErrChangedSince) {
+		t.Fatalf("DeleteChirp() of a changed chirp error = %v, want ErrChangedSince", err)
+	}
+	err = s.DeleteChirp(ctx, other.User.ID, moderator, chirp.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions, err := store.ListModerationActions(ctx, database.ListModerationActionsParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Action != ActionDeleteChirp {
+		t.Errorf("moderation actions = %+v, want the deletion recorded", actions)
+	}
+
+	err = s.DeleteChirp(ctx, author.User.ID, auth.Access{}, chirp.ID, nil)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteChirp() of a deleted chirp error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestResolveReportRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	s := newTestService(store)
+	reporter, err := s.CreateUser(ctx, "reporter@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reported, err := s.CreateUser(ctx, "reported@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := store.CreateReport(ctx, database.CreateReportParams{
+		ReporterID:     reporter.User.ID,
+		ReportedUserID: reported.User.ID,
+		Reason:         "spam",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The report is closed before the moderation action is recorded, which
+	// fails because the moderator doesn't exist; closing it must be undone.
+	_, err = s.ResolveReport(ctx, uuid.New(), report.ID, ActionDismiss, "")
+	if err == nil {
+		t.Fatal("ResolveReport() by an unknown moderator succeeded")
+	}
+	report, err = store.GetReport(ctx, report.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != "open" {
+		t.Errorf("status = %q after a failed action, want open", report.Status)
+	}
+
+	_, err = s.ResolveReport(ctx, reporter.User.ID, report.ID, ActionHideChirp, "")
+	if !errors.Is(err, ErrNotChirpReport) {
+		t.Errorf("ResolveReport(hide_chirp) error = %v, want ErrNotChirpReport", err)
+	}
+}
+
+func TestStartConversation(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(memstore.New())
+	var users []uuid.UUID
+	for _, email := range []string{"walt@example.com", "jesse@example.com"} {
+		signup, err := s.CreateUser(ctx, email, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, signup.User.ID)
+	}
+
+	first, created, err := s.StartConversation(ctx, users[0], users[1:])
+	if err != nil || !created {
+		t.Fatalf("StartConversation() = %v, %v, want a new conversation", created, err)
+	}
+	again, created, err := s.StartConversation(ctx, users[1], users[:1])
+	if err != nil || created || again.ID != first.ID {
+		t.Errorf("StartConversation() again = %s, %v, %v, want the existing conversation %s", again.ID, created, err, first.ID)
+	}
+
+	_, _, err = s.StartConversation(ctx, users[0], []uuid.UUID{uuid.New()})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("StartConversation() with an unknown member error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// AccountDeletionGraceDays is how long a deleted account can be restored by
+// logging in again before it is removed for good.
+const AccountDeletionGraceDays = 30
+
+const emailVerificationTTL = 24 * time.Hour
+
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// Signup is an account and a token that confirms its email address.
+type Signup struct {
+	User              database.User
+	VerificationToken string
+}
+
+// Session is a logged-in user and their new refresh token.
+type Session struct {
+	User         database.User
+	RefreshToken string
+}
+
+// UserUpdate is a change to an account. Nil fields are left alone; either
+// change needs the current password.
+type UserUpdate struct {
+	Email           *string
+	Password        *string
+	CurrentPassword string
+}
+
+// UpdatedUser is an account after an update. VerificationToken is set if the
+// email changed and has to be confirmed again. RefreshToken is set if the
+// password changed, which signs out every other session.
+type UpdatedUser struct {
+	User              database.User
+	VerificationToken string
+	RefreshToken      string
+}
+
+// CreateUser signs up a new user and issues a token to verify their email.
+// Nobody is made an admin until they have verified their email, so signing
+// up with Options.AdminEmail grants nothing by itself.
+func (s *Service) CreateUser(ctx context.Context, email, password string) (Signup, error) {
+	hashedPW, err := auth.HashPassword(password)
+	if err != nil {
+		return Signup{}, err
+	}
+
+	var signup Signup
+	err = s.inTx(ctx, func(q database.Querier) error {
+		now := time.Now()
+		user, err := q.CreateUser(ctx, database.CreateUserParams{
+			ID:             uuid.New(),
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			HashedPassword: hashedPW,
+			Email:          email,
+		})
+		if err != nil {
+			return err
+		}
+		token, err := newEmailVerification(ctx, q, user)
+		if err != nil {
+			return err
+		}
+		signup = Signup{User: user, VerificationToken: token}
+		return nil
+	})
+	if isUniqueViolation(err) {
+		return Signup{}, ErrEmailTaken
+	}
+	return signup, err
+}
+
+// BootstrapAdmin grants the admin role to user if they have verified an
+// email matching Options.AdminEmail, so a fresh deployment has someone who
+// can grant every other role. It reports whether the role was newly granted.
+func (s *Service) BootstrapAdmin(ctx context.Context, user database.User) (bool, error) {
+	if !user.EmailVerifiedAt.Valid {
+		return false, nil
+	}
+	return s.bootstrapAdmin(ctx, s.store, user.ID, user.Email)
+}
+
+// bootstrapAdmin grants the admin role to userID if email, which the caller
+// must know is verified, matches Options.AdminEmail.
+func (s *Service) bootstrapAdmin(ctx context.Context, q database.Querier, userID uuid.UUID, email string) (bool, error) {
+	if s.opts.AdminEmail == "" || !strings.EqualFold(email, s.opts.AdminEmail) {
+		return false, nil
+	}
+	granted, err := q.GrantRole(ctx, database.GrantRoleParams{
+		UserID: userID,
+		Role:   RoleAdmin,
+	})
+	return granted > 0, err
+}
+
+// Login checks a user's password and starts a session. Logging in during
+// the grace period cancels a pending account deletion; once it has run out
+// the account is as good as gone.
+func (s *Service) Login(ctx context.Context, email, password string) (Session, error) {
+	user, err := s.store.FindUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	if err := s.checkPassword(ctx, user.ID, password); err != nil {
+		return Session{}, err
+	}
+	if user.SuspendedAt.Valid {
+		return Session{}, ErrSuspended
+	}
+
+	var session Session
+	err = s.inTx(ctx, func(q database.Querier) error {
+		session.User = user
+		if user.DeletedAt.Valid {
+			restored, err := q.RestoreUser(ctx, database.RestoreUserParams{
+				ID:        user.ID,
+				GraceDays: AccountDeletionGraceDays,
+			})
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrInvalidCredentials
+			}
+			if err != nil {
+				return err
+			}
+			session.User = restored
+		}
+		token, err := s.createRefreshToken(ctx, q, user.ID)
+		if err != nil {
+			return err
+		}
+		session.RefreshToken = token
+		return nil
+	})
+	return session, err
+}
+
+// UpdateUser changes a user's email and/or password after checking their
+// current password.
+func (s *Service) UpdateUser(ctx context.Context, userID uuid.UUID, update UserUpdate) (UpdatedUser, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return UpdatedUser{}, err
+	}
+	if err := s.checkPassword(ctx, userID, update.CurrentPassword); err != nil {
+		return UpdatedUser{}, err
+	}
+	var hashedPW string
+	if update.Password != nil {
+		hashedPW, err = auth.HashPassword(*update.Password)
+		if err != nil {
+			return UpdatedUser{}, err
+		}
+	}
+
+	var updated UpdatedUser
+	err = s.inTx(ctx, func(q database.Querier) error {
+		updated = UpdatedUser{User: user}
+		var err error
+		if update.Email != nil && *update.Email != user.Email {
+			updated.User, err = q.UpdateUserEmail(ctx, database.UpdateUserEmailParams{
+				ID:    userID,
+				Email: *update.Email,
+			})
+			if err != nil {
+				return err
+			}
+			updated.VerificationToken, err = newEmailVerification(ctx, q, updated.User)
+			if err != nil {
+				return err
+			}
+		}
+		if update.Password != nil {
+			updated.User, err = q.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+				ID:             userID,
+				HashedPassword: hashedPW,
+			})
+			if err != nil {
+				return err
+			}
+			err = q.RevokeAllRefreshTokensForUser(ctx, userID)
+			if err != nil {
+				return err
+			}
+			updated.RefreshToken, err = s.createRefreshToken(ctx, q, userID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if isUniqueViolation(err) {
+		return UpdatedUser{}, ErrEmailTaken
+	}
+	return updated, err
+}
+
+// ResetPassword sets a new password without checking the old one and signs
+// the user out everywhere. It's for administrators; users go through
+// UpdateUser.
+func (s *Service) ResetPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	hashedPW, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.inTx(ctx, func(q database.Querier) error {
+		_, err := q.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+			ID:             userID,
+			HashedPassword: hashedPW,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return q.RevokeAllRefreshTokensForUser(ctx, userID)
+	})
+}
+
+// DeleteAccount soft-deletes a user after checking their password and signs
+// them out everywhere. It returns when the account will be purged.
+func (s *Service) DeleteAccount(ctx context.Context, userID uuid.UUID, password string) (time.Time, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := s.checkPassword(ctx, user.ID, password); err != nil {
+		return time.Time{}, err
+	}
+
+	err = s.inTx(ctx, func(q database.Querier) error {
+		if err := q.SoftDeleteUser(ctx, userID); err != nil {
+			return err
+		}
+		return q.RevokeAllRefreshTokensForUser(ctx, userID)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().UTC().AddDate(0, 0, AccountDeletionGraceDays), nil
+}
+
+// VerifyEmail uses up a verification token and marks the address it was
+// sent to as verified. If the user has changed their email since, nothing
+// is verified. Verifying Options.AdminEmail makes the user an admin.
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	return s.inTx(ctx, func(q database.Querier) error {
+		verification, err := q.UseEmailVerificationToken(ctx, token)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+		verified, err := q.MarkEmailVerified(ctx, database.MarkEmailVerifiedParams{
+			ID:    verification.UserID,
+			Email: verification.Email,
+		})
+		if err != nil {
+			return err
+		}
+		if verified == 0 {
+			return ErrInvalidToken
+		}
+		_, err = s.bootstrapAdmin(ctx, q, verification.UserID, verification.Email)
+		return err
+	})
+}
+
+// ResendEmailVerification issues a new token to verify the user's current
+// email, for anyone whose first one expired or never arrived, including
+// accounts from before verification existed. Earlier tokens stop working.
+func (s *Service) ResendEmailVerification(ctx context.Context, userID uuid.UUID) (Signup, error) {
+	var signup Signup
+	err := s.inTx(ctx, func(q database.Querier) error {
+		user, err := q.GetUserByID(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && user.DeletedAt.Valid) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt.Valid {
+			return ErrAlreadyVerified
+		}
+		token, err := newEmailVerification(ctx, q, user)
+		if err != nil {
+			return err
+		}
+		signup = Signup{User: user, VerificationToken: token}
+		return nil
+	})
+	return signup, err
+}
+
+// activeUser returns a user who exists and hasn't deleted their account.
+func (s *Service) activeUser(ctx context.Context, userID uuid.UUID) (database.User, error) {
+	user, err := s.store.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && user.DeletedAt.Valid) {
+		return database.User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// checkPassword compares password with the user's hash, which is read from
+// the store on its own since cached users leave it out.
+func (s *Service) checkPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	hash, err := s.store.GetUserPasswordHash(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return err
+	}
+	if auth.CheckPasswordHash(password, hash) != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (s *Service) createRefreshToken(ctx context.Context, q database.Querier, userID uuid.UUID) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(s.opts.RefreshTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// newEmailVerification issues a fresh verification token for the user's
+// current email address. Earlier tokens stop working, so only the most
+// recent address can be confirmed.
+func newEmailVerification(ctx context.Context, q database.Querier, user database.User) (string, error) {
+	err := q.InvalidateEmailVerificationTokens(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = q.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		Token:     token,
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
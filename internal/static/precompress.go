@@ -0,0 +1,112 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Precompress writes a .br and a .gz beside every text file under dir, at
+// the best compression each format has, for New to pick up. It is slow
+// enough to belong in a build step rather than server startup; run it with
+// go generate whenever a file changes, since New refuses variants that no
+// longer match their file. Pages are rendered when they're loaded, so they
+// are compressed then instead.
+func Precompress(dir string) error {
+	return fs.WalkDir(os.DirFS(dir), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		// Go files are the package embedding the rest, not files to serve.
+		switch path.Ext(name) {
+		case ".br", ".gz", ".html", ".go":
+			return nil
+		}
+		if !d.Type().IsRegular() || !compressible(mime.TypeByExtension(path.Ext(name))) {
+			return nil
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		compressed, err := compressBrotli(body, brotli.BestCompression)
+		if err != nil {
+			return err
+		}
+		if err := writeVariant(file+".br", body, compressed); err != nil {
+			return err
+		}
+		compressed, err = compressGzip(body, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+		return writeVariant(file+".gz", body, compressed)
+	})
+}
+
+// writeVariant writes a compressed copy of body if it's any smaller, and
+// otherwise removes an old one.
+func writeVariant(name string, body, compressed []byte) error {
+	if len(compressed) >= len(body) {
+		err := os.Remove(name)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return os.WriteFile(name, compressed, 0o644)
+}
+
+func compressGzip(body []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	// gzip headers carry a timestamp; leaving it zero keeps the output
+	// the same from one run to the next.
+	zw.Write(body)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func compressBrotli(body []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	bw := brotli.NewWriterLevel(&buf, level)
+	bw.Write(body)
+	if err := bw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress undoes compressGzip or compressBrotli.
+func decompress(encoding string, compressed []byte) ([]byte, error) {
+	var r io.Reader = brotli.NewReader(bytes.NewReader(compressed))
+	if encoding == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,321 @@
+// Package static serves a front end's files from an fs.FS, typically one
+// embedded into the binary, so only the files meant for browsers are ever
+// reachable.
+//
+// Every file is loaded once, when the Handler is made. Each gets a second
+// name with a hash of its contents, such as assets/app.3f9c2a61d0b4e587.js,
+// which is served with a year-long immutable cache lifetime: a changed file
+// gets a new name, so browsers never need to revalidate. HTML pages are Go
+// templates that link to files through the asset function, which returns the
+// hashed URL, and are always revalidated so they pick up new hashes.
+//
+// Text files are served brotli or gzip compressed to clients that accept
+// either. Precompress writes the compressed copies beside each file, as
+// name.br and name.gz, at the best compression; any a file lacks are made
+// when it's loaded, at a level quick enough for startup.
+//
+// Directories are never listed. A path with no extension that matches no
+// file serves index.html, so a single page app can route on the client.
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	// immutable is the Cache-Control for content-hashed names.
+	immutable = "public, max-age=31536000, immutable"
+	// revalidate is the Cache-Control for everything else, which browsers
+	// may keep but must check with the ETag before using.
+	revalidate = "no-cache"
+)
+
+// file is one file ready to serve, with its compressed variants if any are
+// smaller.
+type file struct {
+	contentType string
+	hash        string
+	body        []byte
+	brotli      []byte
+	gzip        []byte
+}
+
+type route struct {
+	file      *file
+	immutable bool
+}
+
+// Handler serves the files of an fs.FS. It is safe for concurrent use.
+type Handler struct {
+	routes map[string]route
+}
+
+// New loads every file in fsys, which is served at prefix, the URL path
+// that asset links in HTML pages start with. Dot files are skipped.
+func New(fsys fs.FS, prefix string) (*Handler, error) {
+	h := &Handler{routes: map[string]route{}}
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	if prefix == "//" {
+		prefix = "/"
+	}
+
+	var names, pages []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case !d.Type().IsRegular():
+		case path.Ext(name) == ".br", path.Ext(name) == ".gz":
+			// Loaded alongside the file they compress.
+		case path.Ext(name) == ".html":
+			pages = append(pages, name)
+		default:
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Assets first, so pages can link to their hashed names.
+	hashed := map[string]string{}
+	for _, name := range names {
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		f := newFile(name, body)
+		if err := f.loadVariants(fsys, name); err != nil {
+			return nil, err
+		}
+		hashed[name] = hashedName(name, f.hash)
+		h.routes[name] = route{file: f}
+		h.routes[hashed[name]] = route{file: f, immutable: true}
+	}
+
+	asset := func(name string) (string, error) {
+		name = strings.TrimPrefix(name, "/")
+		hashedName, ok := hashed[name]
+		if !ok {
+			return "", fmt.Errorf("no asset named %q", name)
+		}
+		return prefix + hashedName, nil
+	}
+	for _, name := range pages {
+		body, err := renderPage(fsys, name, asset)
+		if err != nil {
+			return nil, err
+		}
+		f := newFile(name, body)
+		// Any precompressed copy on disk is of the template, not the page.
+		if err := f.loadVariants(nil, name); err != nil {
+			return nil, err
+		}
+		h.routes[name] = route{file: f}
+	}
+	return h, nil
+}
+
+func renderPage(fsys fs.FS, name string, asset func(string) (string, error)) ([]byte, error) {
+	src, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"asset": asset}).Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newFile(name string, body []byte) *file {
+	sum := sha256.Sum256(body)
+	f := &file{
+		contentType: mime.TypeByExtension(path.Ext(name)),
+		hash:        hex.EncodeToString(sum[:8]),
+		body:        body,
+	}
+	if f.contentType == "" {
+		f.contentType = http.DetectContentType(body)
+	}
+	return f
+}
+
+// loadVariants picks up name.br and name.gz from fsys, which may be nil,
+// and compresses text files that lack either. A variant that doesn't
+// decompress to the file was left behind by an edit, and is an error.
+func (f *file) loadVariants(fsys fs.FS, name string) error {
+	if fsys != nil {
+		var err error
+		f.brotli, err = readVariant(fsys, name, "br", f.body)
+		if err != nil {
+			return err
+		}
+		f.gzip, err = readVariant(fsys, name, "gzip", f.body)
+		if err != nil {
+			return err
+		}
+	}
+	if compressible(f.contentType) {
+		var err error
+		if f.brotli == nil {
+			f.brotli, err = compressBrotli(f.body, brotli.DefaultCompression)
+			if err != nil {
+				return err
+			}
+		}
+		if f.gzip == nil {
+			f.gzip, err = compressGzip(f.body, gzip.DefaultCompression)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// A variant that isn't smaller only costs the client time to decode.
+	if len(f.brotli) >= len(f.body) {
+		f.brotli = nil
+	}
+	if len(f.gzip) >= len(f.body) {
+		f.gzip = nil
+	}
+	return nil
+}
+
+func readVariant(fsys fs.FS, name, encoding string, body []byte) ([]byte, error) {
+	variant := name + ".gz"
+	if encoding == "br" {
+		variant = name + ".br"
+	}
+	compressed, err := fs.ReadFile(fsys, variant)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	decompressed, err := decompress(encoding, compressed)
+	if err != nil || !bytes.Equal(decompressed, body) {
+		return nil, fmt.Errorf("%s doesn't match %s; run go generate to compress it again", variant, name)
+	}
+	return compressed, nil
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "javascript") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "image/svg+xml"
+}
+
+// hashedName puts hash before the extension of name.
+func hashedName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// ServeHTTP serves the file at the request's path, which should already
+// have the Handler's prefix stripped.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	rt, ok := h.routes[name]
+	if !ok && path.Ext(name) == "" {
+		// Directories land here too, rather than being listed.
+		rt, ok = h.routes["index.html"]
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	serve(w, r, rt)
+}
+
+func serve(w http.ResponseWriter, r *http.Request, rt route) {
+	f := rt.file
+	header := w.Header()
+	header.Set("Content-Type", f.contentType)
+	if rt.immutable {
+		header.Set("Cache-Control", immutable)
+	} else {
+		header.Set("Cache-Control", revalidate)
+	}
+
+	body, etag := f.body, f.hash
+	if f.brotli != nil || f.gzip != nil {
+		header.Add("Vary", "Accept-Encoding")
+		acceptEncoding := r.Header.Get("Accept-Encoding")
+		switch {
+		case f.brotli != nil && accepts(acceptEncoding, "br"):
+			body, etag = f.brotli, f.hash+"-br"
+			header.Set("Content-Encoding", "br")
+		case f.gzip != nil && accepts(acceptEncoding, "gzip"):
+			body, etag = f.gzip, f.hash+"-gz"
+			header.Set("Content-Encoding", "gzip")
+		}
+	}
+	header.Set("ETag", strconv.Quote(etag))
+	// ServeContent handles If-None-Match, ranges and HEAD.
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// accepts reports whether an Accept-Encoding header allows coding. A q of 0
+// refuses a coding, and * stands for any coding not listed.
+func accepts(header, coding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(key, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		switch {
+		case strings.EqualFold(name, coding):
+			return q > 0
+		case name == "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
@@ -0,0 +1,232 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var appJS = strings.Repeat("console.log('chirp');\n", 50)
+
+func testFS() fstest.MapFS {
+	// app.js comes with a brotli copy only, so its gzip copy is made at
+	// load; app.css comes with neither.
+	appJSBrotli, err := compressBrotli([]byte(appJS), 11)
+	if err != nil {
+		panic(err)
+	}
+	return fstest.MapFS{
+		"index.html":         {Data: []byte(`<script src="{{asset "assets/app.js"}}"></script>` + strings.Repeat("<p>Chirp</p>\n", 20))},
+		"index.html.gz":      {Data: []byte("stale")},
+		"assets/app.js":      {Data: []byte(appJS)},
+		"assets/app.js.br":   {Data: appJSBrotli},
+		"assets/app.css":     {Data: []byte(strings.Repeat("p { color: red; }\n", 20))},
+		"assets/logo.png":    {Data: []byte("\x89PNG\r\n\x1a\n")},
+		".env":               {Data: []byte("JWT_SECRET=hunter2")},
+		".git/config":        {Data: []byte("[core]")},
+		"assets/.DS_Store":   {Data: []byte("junk")},
+		"assets/empty/.keep": {Data: nil},
+	}
+}
+
+func get(t *testing.T, h http.Handler, path string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHashedAssets(t *testing.T) {
+	h, err := New(testFS(), "/app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := get(t, h, "/")
+	if index.Code != http.StatusOK || index.Header().Get("Cache-Control") != revalidate {
+		t.Fatalf("GET / = %d, Cache-Control %q", index.Code, index.Header().Get("Cache-Control"))
+	}
+	match := regexp.MustCompile(`/app/(assets/app\.[0-9a-f]{16}\.js)`).FindStringSubmatch(index.Body.String())
+	if match == nil {
+		t.Fatalf("index wasn't rendered with a hashed link: %s", index.Body)
+	}
+
+	hashed := get(t, h, "/"+match[1])
+	if hashed.Code != http.StatusOK || hashed.Header().Get("Cache-Control") != immutable {
+		t.Fatalf("GET hashed asset = %d, Cache-Control %q", hashed.Code, hashed.Header().Get("Cache-Control"))
+	}
+	if hashed.Body.String() != appJS {
+		t.Errorf("hashed asset body = %q", hashed.Body)
+	}
+	if got := hashed.Header().Get("Content-Type"); !strings.Contains(got, "javascript") {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	plain := get(t, h, "/assets/app.js")
+	if plain.Code != http.StatusOK || plain.Header().Get("Cache-Control") != revalidate {
+		t.Errorf("GET unhashed asset = %d, Cache-Control %q", plain.Code, plain.Header().Get("Cache-Control"))
+	}
+
+	etag := plain.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if rec := get(t, h, "/assets/app.js", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("revalidation = %d, want 304", rec.Code)
+	}
+}
+
+func TestNewRejectsUnknownAsset(t *testing.T) {
+	fsys := fstest.MapFS{"index.html": {Data: []byte(`{{asset "missing.js"}}`)}}
+	if _, err := New(fsys, "/"); err == nil {
+		t.Error("New() succeeded with a link to a missing asset")
+	}
+}
+
+func TestCompressedVariants(t *testing.T) {
+	h, err := New(testFS(), "/app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"brotli preferred", "/assets/app.js", "gzip, br", "br"},
+		{"brotli refused", "/assets/app.js", "gzip, br;q=0", "gzip"},
+		{"wildcard", "/assets/app.js", "*", "br"},
+		{"identity", "/assets/app.js", "", ""},
+		{"page compressed at load", "/index.html", "gzip, br", "br"},
+		{"page gzipped at load", "/index.html", "gzip", "gzip"},
+		{"asset compressed at load", "/assets/app.css", "br", "br"},
+		{"binary left alone", "/assets/logo.png", "gzip, br", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(t, h, tt.path, "Accept-Encoding", tt.acceptEncoding)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if tt.wantEncoding != "" && !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+				t.Error("compressed response doesn't vary on Accept-Encoding")
+			}
+			if tt.wantEncoding == "gzip" {
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, err := io.ReadAll(zr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// The page is gzipped after rendering, not from the stale
+				// copy of its template.
+				if bytes.Contains(body, []byte("stale")) || bytes.Contains(body, []byte("{{")) {
+					t.Errorf("gzipped body = %q", body)
+				}
+			}
+		})
+	}
+}
+
+func TestStaleVariant(t *testing.T) {
+	fsys := testFS()
+	fsys["assets/app.js"] = &fstest.MapFile{Data: []byte(appJS + "// edited\n")}
+	_, err := New(fsys, "/app/")
+	if err == nil || !strings.Contains(err.Error(), "assets/app.js.br") {
+		t.Errorf("New() with an out of date brotli copy error = %v", err)
+	}
+}
+
+func TestPrecompress(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":      `<script src="{{asset "assets/app.js"}}"></script>`,
+		"assets/app.js":   appJS,
+		"assets/tiny.js":  "1",
+		"assets/logo.png": "\x89PNG\r\n\x1a\n",
+	}
+	for name, body := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Precompress(dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"assets/app.js.br":   true,
+		"assets/app.js.gz":   true,
+		"assets/tiny.js.br":  false,
+		"assets/logo.png.br": false,
+		"index.html.br":      false,
+	} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if got := err == nil; got != want {
+			t.Errorf("%s written = %v, want %v", name, got, want)
+		}
+	}
+
+	h, err := New(os.DirFS(dir), "/app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := get(t, h, "/assets/app.js", "Accept-Encoding", "br")
+	if got := rec.Header().Get("Content-Encoding"); got != "br" {
+		t.Fatalf("Content-Encoding = %q, want br", got)
+	}
+	body, err := decompress("br", rec.Body.Bytes())
+	if err != nil || string(body) != appJS {
+		t.Errorf("brotli body decodes to %q, %v", body, err)
+	}
+}
+
+func TestRouting(t *testing.T) {
+	h, err := New(testFS(), "/app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := get(t, h, "/index.html").Body.String()
+
+	// Paths without an extension get the page, even ones naming a skipped
+	// file or a directory.
+	for _, path := range []string{"/login", "/chirps/123", "/assets", "/assets/", "/assets/empty/", "/.git/config"} {
+		rec := get(t, h, path)
+		if rec.Code != http.StatusOK || rec.Body.String() != index {
+			t.Errorf("GET %s = %d %q, want the index page", path, rec.Code, rec.Body)
+		}
+	}
+	for _, path := range []string{"/.env", "/assets/.DS_Store", "/assets/app.js.br", "/index.html.gz", "/missing.js", "/../.env"} {
+		if rec := get(t, h, path); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST / = %d, Allow %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DB wraps a database.DBTX so every sqlc query runs in a child span named
+// after the query.
+type DB struct {
+	db database.DBTX
+}
+
+// WrapDB returns db with tracing. Pass the result to database.New.
+func WrapDB(db database.DBTX) *DB {
+	return &DB{db: db}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+	result, err := d.db.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+	stmt, err := d.db.PrepareContext(ctx, query)
+	recordError(span, err)
+	return stmt, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+// QueryRowContext can't see errors, which sql.Row defers until Scan; the
+// span covers running the query only.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+	return d.db.QueryRowContext(ctx, query, args...)
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := queryName(query)
+	return Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// queryName pulls the query name out of the "-- name: GetUser :one" comment
+// sqlc puts at the top of every query.
+func queryName(query string) string {
+	const prefix = "-- name: "
+	if !strings.HasPrefix(query, prefix) {
+		return "query"
+	}
+	fields := strings.Fields(strings.TrimPrefix(query, prefix))
+	if len(fields) == 0 {
+		return "query"
+	}
+	return fields[0]
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil || err == sql.ErrNoRows {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
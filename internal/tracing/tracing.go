@@ -0,0 +1,76 @@
+// Package tracing sets up OpenTelemetry and instruments the database.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/dandytron/chirpy.git/internal/tracing"
+
+// Exporters accepted by Setup.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Options configures Setup.
+type Options struct {
+	// Exporter is "otlp", "stdout" or "none" (the default). The OTLP exporter
+	// is configured through the standard OTEL_EXPORTER_OTLP_* variables.
+	Exporter    string
+	ServiceName string
+	// Writer receives spans from the stdout exporter.
+	Writer io.Writer
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. The propagator is installed even without an exporter, so
+// inbound traceparent headers are still read. The returned function flushes
+// and stops the exporter.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(opts.Exporter) {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout, "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(opts.Writer))
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create %s trace exporter: %w", opts.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(opts.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer Chirpy's own spans are created with.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
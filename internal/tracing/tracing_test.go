@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return recorder
+}
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"-- name: GetUserByID :one\nSELECT 1", "GetUserByID"},
+		{"-- name: DeleteUsers :exec", "DeleteUsers"},
+		{"SELECT 1", "query"},
+		{"-- name: ", "query"},
+	}
+	for _, tt := range tests {
+		if got := queryName(tt.query); got != tt.want {
+			t.Errorf("queryName(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+type fakeDBTX struct {
+	err error
+}
+
+func (f fakeDBTX) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, f.err
+}
+
+func (f fakeDBTX) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, f.err
+}
+
+func (f fakeDBTX) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, f.err
+}
+
+func (f fakeDBTX) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestDBCreatesChildSpans(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	db := WrapDB(fakeDBTX{err: errors.New("connection refused")})
+	db.ExecContext(ctx, "-- name: DeleteUsers :exec\nDELETE FROM users")
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	query := spans[0]
+	if query.Name() != "DeleteUsers" {
+		t.Errorf("span name = %q, want DeleteUsers", query.Name())
+	}
+	if query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("query span isn't a child of the request span")
+	}
+	if query.Status().Code != codes.Error {
+		t.Errorf("span status = %v, want Error", query.Status().Code)
+	}
+}
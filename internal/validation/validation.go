@@ -0,0 +1,195 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxBodyBytes is the largest request body DecodeJSON will read.
+const MaxBodyBytes = 1 << 20
+
+// Machine-readable error codes returned in the error envelope.
+const (
+	CodeInvalidJSON      = "invalid_json"
+	CodeUnknownField     = "unknown_field"
+	CodeBodyTooLarge     = "body_too_large"
+	CodeValidationFailed = "validation_failed"
+)
+
+// FieldError describes a problem with a single request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a client error found while decoding or validating a request. It
+// carries the HTTP status and error code to respond with.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return e.Message + ": " + strings.Join(parts, "; ")
+}
+
+// DecodeJSON reads a single JSON object from the request body into dst. It
+// rejects bodies larger than MaxBodyBytes, fields dst doesn't declare and
+// trailing data after the object.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	return decodeJSON(w, r, dst, true)
+}
+
+// DecodeJSONAllowUnknown is DecodeJSON for payloads we don't control, such as
+// third-party webhooks, where new fields may appear at any time.
+func DecodeJSONAllowUnknown(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	return decodeJSON(w, r, dst, false)
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}, strict bool) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(dst)
+	if err != nil {
+		return decodeError(err)
+	}
+	if decoder.More() {
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeInvalidJSON,
+			Message: "Request body must contain a single JSON object",
+		}
+	}
+	return nil
+}
+
+func decodeError(err error) *Error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &Error{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    CodeBodyTooLarge,
+			Message: fmt.Sprintf("Request body must not be larger than %d bytes", maxBytesErr.Limit),
+		}
+	case errors.Is(err, io.EOF):
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeInvalidJSON,
+			Message: "Request body must not be empty",
+		}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeInvalidJSON,
+			Message: "Request body contains malformed JSON",
+		}
+	case errors.As(err, &typeErr):
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeInvalidJSON,
+			Message: "Request body contains a field of the wrong type",
+			Fields: []FieldError{{
+				Field:   typeErr.Field,
+				Message: "must be a " + typeErr.Type.String(),
+			}},
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields.
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeUnknownField,
+			Message: "Request body contains an unknown field",
+			Fields: []FieldError{{
+				Field:   field,
+				Message: "is not allowed",
+			}},
+		}
+	default:
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeInvalidJSON,
+			Message: "Couldn't decode request body",
+		}
+	}
+}
+
+// Validator collects field errors so a client sees every problem with a
+// request at once instead of one per round trip.
+type Validator struct {
+	fields []FieldError
+}
+
+// Check records message against field unless ok is true.
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok {
+		v.fields = append(v.fields, FieldError{Field: field, Message: message})
+	}
+}
+
+// Required checks that value is not blank.
+func (v *Validator) Required(field, value string) {
+	v.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// MaxLength checks that value has at most max characters.
+func (v *Validator) MaxLength(field, value string, max int) {
+	v.Check(utf8.RuneCountInString(value) <= max, field, fmt.Sprintf("must be at most %d characters", max))
+}
+
+// Email checks that value looks like an email address.
+func (v *Validator) Email(field, value string) {
+	at := strings.Index(value, "@")
+	v.Check(at > 0 && at < len(value)-1 && !strings.ContainsAny(value, " \t\r\n"), field, "must be a valid email address")
+}
+
+// OneOf checks that value is one of the allowed values.
+func (v *Validator) OneOf(field, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.Check(false, field, "must be one of: "+strings.Join(allowed, ", "))
+}
+
+// Valid reports whether no checks have failed.
+func (v *Validator) Valid() bool {
+	return len(v.fields) == 0
+}
+
+// Err returns the collected field errors as a 422 *Error, or nil if every
+// check passed.
+func (v *Validator) Err() error {
+	if v.Valid() {
+		return nil
+	}
+	return &Error{
+		Status:  http.StatusUnprocessableEntity,
+		Code:    CodeValidationFailed,
+		Message: "Request failed validation",
+		Fields:  v.fields,
+	}
+}
@@ -0,0 +1,135 @@
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type params struct {
+		Email string `json:"email"`
+		Age   int    `json:"age"`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		strict     bool
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{
+			name:   "Valid body",
+			body:   `{"email": "a@b.com", "age": 3}`,
+			strict: true,
+		},
+		{
+			name:       "Empty body",
+			body:       ``,
+			strict:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidJSON,
+		},
+		{
+			name:       "Malformed JSON",
+			body:       `{"email": `,
+			strict:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidJSON,
+		},
+		{
+			name:       "Wrong type",
+			body:       `{"age": "three"}`,
+			strict:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidJSON,
+			wantField:  "age",
+		},
+		{
+			name:       "Unknown field",
+			body:       `{"email": "a@b.com", "admin": true}`,
+			strict:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeUnknownField,
+			wantField:  "admin",
+		},
+		{
+			name:   "Unknown field allowed",
+			body:   `{"email": "a@b.com", "admin": true}`,
+			strict: false,
+		},
+		{
+			name:       "Trailing data",
+			body:       `{"email": "a@b.com"}{"email": "c@d.com"}`,
+			strict:     true,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidJSON,
+		},
+		{
+			name:       "Body too large",
+			body:       `{"email": "` + strings.Repeat("a", MaxBodyBytes) + `"}`,
+			strict:     true,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   CodeBodyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			dst := params{}
+
+			var err error
+			if tt.strict {
+				err = DecodeJSON(w, r, &dst)
+			} else {
+				err = DecodeJSONAllowUnknown(w, r, &dst)
+			}
+
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("DecodeJSON() error = %v, want nil", err)
+				}
+				return
+			}
+			var vErr *Error
+			if !errors.As(err, &vErr) {
+				t.Fatalf("DecodeJSON() error = %v, want *Error", err)
+			}
+			if vErr.Status != tt.wantStatus || vErr.Code != tt.wantCode {
+				t.Errorf("DecodeJSON() = %d %s, want %d %s", vErr.Status, vErr.Code, tt.wantStatus, tt.wantCode)
+			}
+			if tt.wantField != "" && (len(vErr.Fields) != 1 || vErr.Fields[0].Field != tt.wantField) {
+				t.Errorf("DecodeJSON() fields = %v, want %s", vErr.Fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	v := Validator{}
+	v.Required("email", "  ")
+	v.Email("email", "not-an-email")
+	v.MaxLength("body", "héllo", 5)
+	v.OneOf("reason", "spam", "spam", "other")
+
+	var vErr *Error
+	if !errors.As(v.Err(), &vErr) {
+		t.Fatalf("Err() = %v, want *Error", v.Err())
+	}
+	if vErr.Status != http.StatusUnprocessableEntity || vErr.Code != CodeValidationFailed {
+		t.Errorf("Err() = %d %s, want %d %s", vErr.Status, vErr.Code, http.StatusUnprocessableEntity, CodeValidationFailed)
+	}
+	if len(vErr.Fields) != 2 {
+		t.Errorf("Err() fields = %v, want 2 errors for email", vErr.Fields)
+	}
+
+	if err := (&Validator{}).Err(); err != nil {
+		t.Errorf("empty Validator Err() = %v, want nil", err)
+	}
+}
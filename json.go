@@ -2,30 +2,76 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+
+	"github.com/dandytron/chirpy.git/internal/validation"
 )
 
+// respondWithError writes the standard error envelope. Decoding and
+// validation failures from the validation package carry their own status,
+// code and field errors, which take precedence over code and msg.
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
+	type errorResponse struct {
+		Error     string                  `json:"error"`
+		Code      string                  `json:"code"`
+		Fields    []validation.FieldError `json:"fields,omitempty"`
+		RequestID string                  `json:"request_id,omitempty"`
+	}
+
+	resp := errorResponse{
+		Error:     msg,
+		Code:      errorCode(code),
+		RequestID: w.Header().Get(requestIDHeader),
+	}
+	var vErr *validation.Error
+	if errors.As(err, &vErr) {
+		code = vErr.Status
+		resp.Error = vErr.Message
+		resp.Code = vErr.Code
+		resp.Fields = vErr.Fields
 	}
 	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+		slog.Error("Responding with 5XX error", "message", msg, "status", code, "error", err, "request_id", resp.RequestID)
+	} else if err != nil && vErr == nil {
+		slog.Debug("Request failed", "message", msg, "status", code, "error", err, "request_id", resp.RequestID)
 	}
-	type errorResponse struct {
-		Error string `json:"error"`
+	respondWithJSON(w, code, resp)
+}
+
+// errorCode maps an HTTP status to the machine-readable code clients
+// switch on.
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return validation.CodeBodyTooLarge
+	case http.StatusUnprocessableEntity:
+		return validation.CodeValidationFailed
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	}
+	if status > 499 {
+		return "internal_error"
 	}
-	respondWithJSON(w, code, errorResponse{
-		Error: msg,
-	})
+	return "error"
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	response, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		slog.Error("Couldn't marshal JSON response", "error", err)
 		w.WriteHeader(500)
 		return
 	}
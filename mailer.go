@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/dandytron/chirpy.git/internal/config"
+)
+
+// mailer delivers transactional email such as address verification.
+type mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// newMailer picks the mailer for conf. On the dev platform messages are
+// printed to w, and otherwise they're dropped.
+func newMailer(conf config.Config, w io.Writer) mailer {
+	if conf.Platform == "dev" {
+		return consoleMailer{w: w}
+	}
+	return logMailer{}
+}
+
+// logMailer records that a message would have been sent without its body,
+// which may hold a verification token. It is the default until a real mail
+// provider is configured.
+type logMailer struct{}
+
+func (logMailer) Send(ctx context.Context, to, subject, body string) error {
+	slog.WarnContext(ctx, "No mail provider configured; dropping email", "subject", subject)
+	return nil
+}
+
+// consoleMailer prints whole messages to w so verification links can be
+// followed during local development. It bypasses the logger on purpose and is
+// only used on the dev platform.
+type consoleMailer struct {
+	w io.Writer
+}
+
+func (m consoleMailer) Send(ctx context.Context, to, subject, body string) error {
+	_, err := fmt.Fprintf(m.w, "\n--- mail to %s ---\nSubject: %s\n\n%s\n---\n", to, subject, body)
+	return err
+}
@@ -1,26 +1,46 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/dandytron/chirpy.git/internal/cache"
+	"github.com/dandytron/chirpy.git/internal/config"
 	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/logging"
+	"github.com/dandytron/chirpy.git/internal/memstore"
+	"github.com/dandytron/chirpy.git/internal/metrics"
+	"github.com/dandytron/chirpy.git/internal/migrations"
+	"github.com/dandytron/chirpy.git/internal/ratelimit"
+	"github.com/dandytron/chirpy.git/internal/service"
+	"github.com/dandytron/chirpy.git/internal/static"
+	"github.com/dandytron/chirpy.git/internal/tracing"
+	"github.com/dandytron/chirpy.git/web"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
-	fileserverHits  atomic.Int32
-	databaseQueries *database.Queries
-	platform        string
-	jwtsecret       string
-	polkaAPIKey     string
+	metrics         *metrics.Metrics
+	databaseQueries database.Querier
+	service         *service.Service
+	rateLimiter     ratelimit.Store
+	config          config.Config
+	mailer          mailer
+	db              *sql.DB
+	workers         *workerHealth
+	webClient       http.Handler
+	shuttingDown    atomic.Bool
 }
 
 type User struct {
@@ -34,78 +54,192 @@ type User struct {
 }
 
 func main() {
-	const filepathRoot = "."
-	const port = "8080"
-
 	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		log.Fatal("DB_URL must be set")
+
+	fs := config.NewFlagSet(os.Args[0])
+	conf, err := config.Load(fs, os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
 	}
-	platform := os.Getenv("PLATFORM")
-	if platform == "" {
-		log.Fatal("PLATFORM must be set")
+	if err != nil {
+		fatal("Invalid configuration", err)
 	}
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is not set")
+
+	logger, err := logging.New(os.Stdout, logging.Options{
+		Format: conf.LogFormat,
+		Level:  conf.LogLevel,
+	})
+	if err != nil {
+		fatal("Invalid logging configuration", err)
+	}
+	slog.SetDefault(logger)
+
+	if fs.NArg() > 0 {
+		err := runCommand(context.Background(), conf, fs.Args())
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		if err != nil {
+			// Commands are run by people at a terminal, so skip the log
+			// format; usage text in particular reads badly as a log field.
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-	polkaAPIKey := os.Getenv("POLKA_KEY")
-	if polkaAPIKey == "" {
-		log.Fatal("POLKA_KEY environment variable is not set")
+	if err := conf.CheckServer(); err != nil {
+		fatal("Invalid configuration", err)
 	}
-	fmt.Println("Loaded POLKA_KEY:", polkaAPIKey)
-	log.Println("Starting server...")
 
-	db, err := sql.Open("postgres", dbURL)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    conf.TraceExporter,
+		ServiceName: conf.ServiceName,
+		Writer:      os.Stdout,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect with database: %v", err)
-		return
+		fatal("Invalid tracing configuration", err)
+	}
+	slog.Info("Starting server", "platform", conf.Platform)
+
+	var db *sql.DB
+	var store service.Store
+	if conf.Store == "memory" {
+		slog.Warn("Using the in-memory store; nothing is saved when the server stops")
+		store = memstore.New()
+	} else {
+		db, err = sql.Open("postgres", conf.DatabaseURL)
+		if err != nil {
+			fatal("Failed to connect with database", err)
+		}
+		store = service.Postgres(db)
+
+		if err := db.Ping(); err != nil {
+			fatal("Failed to ping database", err)
+		}
+
+		slog.Info("Database connected")
+
+		if conf.AutoMigrate {
+			applied, err := migrations.Up(context.Background(), db)
+			if err != nil {
+				fatal("Failed to apply migrations", err)
+			}
+			slog.Info("Migrations applied", "count", applied)
+		}
 	}
-	dbQueries := database.New(db)
 
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Failed to ping database: %v", err)
+	webClient, err := static.New(web.FS, "/app/")
+	if err != nil {
+		fatal("Couldn't load the web client", err)
 	}
 
-	log.Println("Database connected...")
+	store, err = cachedStore(store, conf, false)
+	if err != nil {
+		fatal("Invalid cache configuration", err)
+	}
 
 	apiCfg := apiConfig{
-		fileserverHits:  atomic.Int32{},
-		databaseQueries: dbQueries,
-		platform:        platform,
-		jwtsecret:       jwtSecret,
-		polkaAPIKey:     polkaAPIKey,
+		metrics:         metrics.New(db),
+		databaseQueries: store,
+		service:         service.New(store, serviceOptions(conf)),
+		rateLimiter:     ratelimit.NewMemoryStore(),
+		db:              db,
+		workers:         newWorkerHealth(),
+		webClient:       webClient,
+		config:          conf,
+		mailer:          newMailer(conf, os.Stdout),
 	}
-
-	mux := http.NewServeMux()
-	srv := &http.Server{
-		Handler: mux,
-		Addr:    ":" + port,
+	if conf.RateLimitStore == "postgres" {
+		apiCfg.rateLimiter = ratelimit.NewPostgresStore(db)
 	}
-	fileserver := http.FileServer(http.Dir(filepathRoot))
-	strippedHandler := http.StripPrefix("/app", fileserver)
-	mux.Handle("/app/", apiCfg.middlewareMetricsIncrementer(strippedHandler))
-	mux.HandleFunc("GET /admin/healthz", handlerReadiness)
 
-	mux.HandleFunc("POST /api/login", apiCfg.loginHandler)
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
+	if conf.AdminEmail != "" {
+		// Whoever signed up with the address isn't trusted until they've
+		// shown they own it.
+		adminUser, err := store.FindUserByEmail(context.Background(), conf.AdminEmail)
+		if err == nil && adminUser.EmailVerifiedAt.Valid {
+			apiCfg.bootstrapAdmin(context.Background(), adminUser)
+		}
+	}
 
-	mux.HandleFunc("POST /api/users", apiCfg.createUserHandler)
-	mux.HandleFunc("PUT /api/users", apiCfg.updateCredentials)
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.chirpyRedHandler)
+	ctx, force, stop := notifyShutdown()
+	defer stop()
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(3)
+	go func() {
+		defer workers.Done()
+		apiCfg.runAccountSweeper(workerCtx, conf.AccountSweepInterval)
+	}()
+	go func() {
+		defer workers.Done()
+		apiCfg.runRateLimitSweeper(workerCtx, rateLimitSweepInterval)
+	}()
+	go func() {
+		defer workers.Done()
+		apiCfg.runIdempotencyKeySweeper(workerCtx, idempotencyKeySweepInterval)
+	}()
+
+	srv := newServer(conf.Addr, apiCfg.routes())
+	slog.Info("Serving", "addr", conf.Addr, "tls", conf.TLSCertFile != "")
+	err = apiCfg.runServer(ctx, force, srv, conf.TLSCertFile, conf.TLSKeyFile, conf.ShutdownDrainDelay)
+	if err != nil {
+		fatal("Server failed", err)
+	}
 
-	mux.HandleFunc("POST /api/chirps", apiCfg.createChirpHandler)
-	mux.HandleFunc("GET /api/chirps", apiCfg.retrieveAllChirpsHandler)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.retrieveSingleChirpHandler)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirpHandler)
+	stopWorkers()
+	if !waitTimeout(&workers, shutdownTimeout) {
+		slog.Warn("Background workers didn't stop in time")
+	}
+	err = shutdownTracing(context.Background())
+	if err != nil {
+		slog.Error("Couldn't flush traces", "error", err)
+	}
+	if db != nil {
+		db.Close()
+	}
+	slog.Info("Server stopped")
+}
 
-	mux.HandleFunc("GET /admin/metrics", apiCfg.adminMetricsHandler)
-	mux.HandleFunc("POST /admin/reset", apiCfg.resetHandler)
+// serviceOptions picks the settings the service layer needs out of conf.
+func serviceOptions(conf config.Config) service.Options {
+	return service.Options{
+		MaxChirpLength:  conf.MaxChirpLength,
+		RefreshTokenTTL: conf.RefreshTokenTTL,
+		AdminEmail:      conf.AdminEmail,
+	}
+}
 
-	log.Println("Routes registered...")
+// cachedStore puts the configured cache in front of store. A memory cache
+// only helps the process holding it, so sharedOnly skips it; commands set it
+// since all they need is for the server's shared entries to be invalidated.
+func cachedStore(store service.Store, conf config.Config, sharedOnly bool) (service.Store, error) {
+	var c cache.Cache
+	switch conf.Cache {
+	case "memory":
+		if sharedOnly {
+			return store, nil
+		}
+		c = cache.NewLRU(conf.CacheSize)
+	case "redis":
+		r, err := cache.NewRedis(conf.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		c = r
+	default:
+		return store, nil
+	}
+	return cache.NewStore(store, c, conf.CacheTTL), nil
+}
 
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(srv.ListenAndServe())
+// fatal logs msg and err and exits.
+func fatal(msg string, err error) {
+	if err != nil {
+		slog.Error(msg, "error", err)
+	} else {
+		slog.Error(msg)
+	}
+	os.Exit(1)
 }
@@ -2,30 +2,73 @@ package main
 
 import (
 	"fmt"
+	"html"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/metrics"
 )
 
-// Middleware wrapper that helps track the state of server hits currently;
-// returns a handler that increments the file server hits and calls the next handler
+// Middleware wrapper that counts requests to the /app file server.
 func (cfg *apiConfig) middlewareMetricsIncrementer(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
+		cfg.metrics.FileserverHits.WithLabelValues().Inc()
 		next.ServeHTTP(w, r)
 	})
 }
 
-// apiConfig function that gets the atomic int32 value, converts it to a string, and sets "Hits: x"
-// where x is the number of hits to the header of the response
-func (cfg *apiConfig) numRequestHandler(w http.ResponseWriter, r *http.Request) {
-	hits := cfg.fileserverHits.Load()
-	hitsStr := fmt.Sprintf("Hits: %d", hits)
-	w.Write([]byte(hitsStr))
+// Middleware wrapper that records request counts, latency, response sizes and
+// in-flight requests, labelled by the route pattern the mux matched so that
+// path parameters don't blow up the number of series.
+func (cfg *apiConfig) middlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		cfg.metrics.RequestsInFlight.Inc()
+		defer cfg.metrics.RequestsInFlight.Dec()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		cfg.metrics.RequestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		cfg.metrics.RequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		cfg.metrics.ResponseSize.WithLabelValues(r.Method, route).Observe(float64(rec.bytes))
+	})
 }
 
-// Function for serving simple HTML on an admin get request
+// Function for serving a simple HTML dashboard on an admin get request. It
+// reads the same registry as /metrics.
 func (cfg *apiConfig) adminMetricsHandler(w http.ResponseWriter, r *http.Request) {
-	hits := cfg.fileserverHits.Load()
+	values, err := cfg.metrics.Snapshot()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't gather metrics", err)
+		return
+	}
+
+	rows := []struct {
+		label  string
+		metric string
+	}{
+		{"Requests served", "chirpy_http_requests_total"},
+		{"Requests in flight", "chirpy_http_requests_in_flight"},
+		{"Chirps created", "chirpy_chirps_created_total"},
+		{"Logins succeeded", `chirpy_logins_total{result="` + metrics.LoginSucceeded + `"}`},
+		{"Logins failed", `chirpy_logins_total{result="` + metrics.LoginFailed + `"}`},
+		{"Webhook events", "chirpy_webhook_events_total"},
+		{"Open database connections", "go_sql_open_connections"},
+		{"Database connections in use", "go_sql_in_use_connections"},
+	}
+	table := ""
+	for _, row := range rows {
+		table += fmt.Sprintf("<tr><td>%s</td><td>%s</td></tr>", html.EscapeString(row.label), strconv.FormatFloat(values[row.metric], 'f', -1, 64))
+	}
+
+	hits := int(values["chirpy_fileserver_hits_total"])
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprintf(`<html><body><h1>Welcome, Chirpy Admin</h1><p>Chirpy has been visited %d times!</p></body></html>`, hits)))
+	w.Write([]byte(fmt.Sprintf(`<html><body><h1>Welcome, Chirpy Admin</h1><p>Chirpy has been visited %d times!</p><table>%s</table></body></html>`, hits, table)))
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// corsExposedHeaders are the response headers browser clients need to read.
+var corsExposedHeaders = strings.Join([]string{
+	"ETag", "Last-Modified", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining",
+	"RateLimit-Reset", "RateLimit-Policy", "Idempotent-Replayed", requestIDHeader,
+}, ", ")
+
+// Middleware wrapper that lets browser clients on the configured origins
+// call the API. Preflight requests are answered here; other requests from an
+// allowed origin get the CORS headers and carry on. Requests from any other
+// origin are served without them, so the browser won't hand the response to
+// the calling page.
+func (cfg *apiConfig) middlewareCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if len(cfg.config.CORSAllowedOrigins) == 0 || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowOrigin, ok := cfg.corsAllowOrigin(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !preflight {
+			if ok {
+				h.Set("Access-Control-Allow-Origin", allowOrigin)
+				h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+				if cfg.config.CORSAllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		if !ok {
+			respondWithError(w, http.StatusForbidden, "Origin not allowed", nil)
+			return
+		}
+		if !slices.Contains(cfg.config.CORSAllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+			respondWithError(w, http.StatusForbidden, "Method not allowed for cross-origin requests", nil)
+			return
+		}
+		for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			header = strings.TrimSpace(header)
+			if header != "" && !slices.ContainsFunc(cfg.config.CORSAllowedHeaders, func(allowed string) bool {
+				return strings.EqualFold(allowed, header)
+			}) {
+				respondWithError(w, http.StatusForbidden, "Header "+header+" not allowed for cross-origin requests", nil)
+				return
+			}
+		}
+
+		h.Set("Access-Control-Allow-Origin", allowOrigin)
+		h.Set("Access-Control-Allow-Methods", strings.Join(cfg.config.CORSAllowedMethods, ", "))
+		h.Set("Access-Control-Allow-Headers", strings.Join(cfg.config.CORSAllowedHeaders, ", "))
+		if cfg.config.CORSAllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if cfg.config.CORSMaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.config.CORSMaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// corsAllowOrigin returns the Access-Control-Allow-Origin value for origin,
+// if it's allowed at all.
+func (cfg *apiConfig) corsAllowOrigin(origin string) (string, bool) {
+	for _, allowed := range cfg.config.CORSAllowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+)
+
+const csrfHeader = "X-CSRF-Token"
+
+// csrfExempt lists the endpoints that change something without
+// authenticating, so a stale session cookie mustn't get in their way.
+var csrfExempt = map[string]bool{
+	"POST /api/login":        true,
+	"POST /api/users":        true,
+	"POST /api/users/verify": true,
+}
+
+// Middleware wrapper that protects cookie-authenticated requests from
+// cross-site request forgery. Browsers attach cookies to requests that other
+// sites trigger, so any request that changes something and authenticates
+// with a token cookie must also send the session's CSRF token in the
+// X-CSRF-Token header, which another site has no way to read. Requests that
+// authenticate with an Authorization header aren't at risk, since browsers
+// never add one on their own.
+func (cfg *apiConfig) middlewareCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if csrfExempt[r.Method+" "+r.URL.Path] || r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		_, hasAccess := auth.GetCookie(r.Header, auth.AccessTokenCookie)
+		session, hasRefresh := auth.GetCookie(r.Header, auth.RefreshTokenCookie)
+		if !hasAccess && !hasRefresh {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The token belongs to the refresh token, which lasts the whole
+		// session; an access cookie on its own can't be checked.
+		if !hasRefresh || !cfg.validCSRFToken(r.Header.Get(csrfHeader), session) {
+			respondWithError(w, http.StatusForbidden, "Missing or invalid CSRF token", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfToken derives the CSRF token for a session from its refresh token.
+// Tying it to the session means nothing needs storing, and a token is
+// useless with any other session.
+func (cfg *apiConfig) csrfToken(session string) string {
+	mac := hmac.New(sha256.New, []byte(cfg.config.JWTSecret))
+	mac.Write([]byte("csrf\x00" + session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (cfg *apiConfig) validCSRFToken(token, session string) bool {
+	return token != "" && hmac.Equal([]byte(token), []byte(cfg.csrfToken(session)))
+}
+
+// Handler that hands a browser client the CSRF token for its session.
+// Cross-origin pages can only read it if CORS lets them.
+func (cfg *apiConfig) csrfTokenHandler(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.GetCookie(r.Header, auth.RefreshTokenCookie)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "No session cookie", nil)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, struct {
+		CSRFToken string `json:"csrf_token"`
+	}{
+		CSRFToken: cfg.csrfToken(session),
+	})
+}
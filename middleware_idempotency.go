@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/dandytron/chirpy.git/internal/ratelimit"
+	"github.com/dandytron/chirpy.git/internal/validation"
+	"github.com/google/uuid"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 255
+	// How long a key is remembered, and so how long a client has to retry.
+	idempotencyKeyTTL = 24 * time.Hour
+	// No request runs longer than the write timeout, so a key still in
+	// flight after this long belongs to an instance that died mid-request
+	// and can be taken over.
+	idempotencyLockTimeout = 2 * writeTimeout
+)
+
+// idempotencyExempt lists endpoints whose responses hold credentials, which
+// shouldn't sit in the database for a day in case of a retry, or set
+// cookies, which a replay wouldn't. A password change returns new tokens.
+var idempotencyExempt = map[string]bool{
+	"POST /api/login":   true,
+	"POST /api/refresh": true,
+	"POST /api/revoke":  true,
+	"PATCH /api/users":  true,
+}
+
+// Middleware wrapper that makes POST and PATCH requests safe to retry. A
+// client sends a unique Idempotency-Key with a request; the first request
+// with that key runs as normal and its response is stored, and retries get
+// the stored response back instead of running again. Keys are scoped to the
+// signed-in user, or to the client's IP address, and are remembered for 24
+// hours. Reusing a key for a different request is refused with 422, and a
+// retry that arrives while the first request is still running gets 409.
+// Only the status, Content-Type and body are stored, so a replay carries no
+// other headers; a response that sets a cookie isn't stored at all.
+func (cfg *apiConfig) middlewareIdempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || !cfg.idempotencyApplies(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			respondWithError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters", nil)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, validation.MaxBodyBytes))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't read request body", err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		id := database.GetIdempotencyKeyParams{Scope: cfg.idempotencyScope(r), Key: key}
+		fingerprint := requestFingerprint(r, body)
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		// If this request runs so long that its key is taken over as
+		// abandoned, the token stops it saving over the new owner's response.
+		lockToken := uuid.New()
+		claimed, err := cfg.databaseQueries.ClaimIdempotencyKey(ctx, database.ClaimIdempotencyKeyParams{
+			Scope:       id.Scope,
+			Key:         id.Key,
+			Fingerprint: fingerprint,
+			LockedAt:    now,
+			ExpiresAt:   now.Add(idempotencyKeyTTL),
+			LockToken:   lockToken,
+			StaleBefore: now.Add(-idempotencyLockTimeout),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check idempotency key", err)
+			return
+		}
+		if claimed == 0 {
+			cfg.replayIdempotentResponse(w, r, id, fingerprint)
+			return
+		}
+
+		// The client that sent the key may well have given up by now, which
+		// is why it will retry; the outcome still has to be saved.
+		saveCtx := context.WithoutCancel(ctx)
+		saved := false
+		defer func() {
+			if !saved {
+				err := cfg.databaseQueries.DeleteIdempotencyKey(saveCtx, database.DeleteIdempotencyKeyParams{
+					Scope:     id.Scope,
+					Key:       id.Key,
+					LockToken: lockToken,
+				})
+				if err != nil {
+					slog.ErrorContext(ctx, "Couldn't release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		rec := &responseCapture{statusRecorder: statusRecorder{ResponseWriter: w, status: http.StatusOK}}
+		next.ServeHTTP(rec, r)
+		// Server errors are usually transient, so let the retry run again.
+		// A cookie can't be replayed, and is likely a credential anyway.
+		if rec.status > 499 || rec.Header().Get("Set-Cookie") != "" {
+			return
+		}
+		completed, err := cfg.databaseQueries.CompleteIdempotencyKey(saveCtx, database.CompleteIdempotencyKeyParams{
+			Scope:               id.Scope,
+			Key:                 id.Key,
+			LockToken:           lockToken,
+			ResponseStatus:      int32(rec.status),
+			ResponseContentType: rec.Header().Get("Content-Type"),
+			ResponseBody:        rec.body.Bytes(),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Couldn't save idempotent response", "error", err)
+			return
+		}
+		if completed == 0 {
+			slog.WarnContext(ctx, "Idempotency key was taken over before the response could be saved")
+		}
+		saved = true
+	})
+}
+
+// replayIdempotentResponse answers a request whose key was already taken.
+func (cfg *apiConfig) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, id database.GetIdempotencyKeyParams, fingerprint string) {
+	stored, err := cfg.databaseQueries.GetIdempotencyKey(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The first request failed and released the key just now.
+		w.Header().Set("Retry-After", "1")
+		respondWithError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check idempotency key", err)
+		return
+	}
+	if stored.Fingerprint != fingerprint {
+		respondWithError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request", nil)
+		return
+	}
+	if stored.ResponseStatus == 0 {
+		w.Header().Set("Retry-After", "1")
+		respondWithError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress", nil)
+		return
+	}
+
+	if stored.ResponseContentType != "" {
+		w.Header().Set("Content-Type", stored.ResponseContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(int(stored.ResponseStatus))
+	w.Write(stored.ResponseBody)
+}
+
+// idempotencyApplies reports whether a request's Idempotency-Key is
+// honoured. PUT and DELETE are already safe to repeat.
+func (cfg *apiConfig) idempotencyApplies(r *http.Request) bool {
+	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+		return false
+	}
+	return strings.HasPrefix(r.URL.Path, "/api/") && !idempotencyExempt[r.Method+" "+r.URL.Path]
+}
+
+// idempotencyScope keeps clients from seeing each other's responses by
+// reusing their keys.
+func (cfg *apiConfig) idempotencyScope(r *http.Request) string {
+	if userID, ok := cfg.requestUserID(r); ok {
+		return "user:" + userID.String()
+	}
+	return "ip:" + ratelimit.ClientIP(r, cfg.config.TrustedProxies).String()
+}
+
+// requestFingerprint identifies what a request asks for, so a key reused
+// for something else can be caught.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseCapture keeps a copy of the response body as it is written.
+type responseCapture struct {
+	statusRecorder
+	body bytes.Buffer
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	c.body.Write(b)
+	return c.statusRecorder.Write(b)
+}
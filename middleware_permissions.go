@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const authenticatedUserKey contextKey = "authenticatedUser"
+
+// authenticatedUser is what middlewareRequirePermission learned from the
+// caller's access token.
+type authenticatedUser struct {
+	ID     uuid.UUID
+	Access auth.Access
+}
+
+// Middleware wrapper that only lets the request through if the caller's access
+// token carries the given permission. Responds 401 without a valid token and
+// 403 without the permission; the handler can read the caller back with
+// authenticatedUserFromContext.
+func (cfg *apiConfig) middlewareRequirePermission(permission string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+			return
+		}
+		userID, access, err := auth.ValidateJWTAccess(token, cfg.config.JWTSecret)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+			return
+		}
+		if !access.HasPermission(permission) {
+			respondWithError(w, http.StatusForbidden, "Missing permission: "+permission, nil)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authenticatedUserKey, authenticatedUser{
+			ID:     userID,
+			Access: access,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func authenticatedUserFromContext(ctx context.Context) authenticatedUser {
+	user, _ := ctx.Value(authenticatedUserKey).(authenticatedUser)
+	return user
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/ratelimit"
+)
+
+// Rate limit policy names, used in bucket keys and the rate limited metric.
+const (
+	rateLimitAuth     = "auth"
+	rateLimitWrites   = "writes"
+	rateLimitReads    = "reads"
+	rateLimitWebhooks = "webhooks"
+)
+
+// authRoutes are the endpoints that take credentials or tokens, which get the
+// tightest limit since they're what password guessing goes after.
+var authRoutes = map[string]bool{
+	"POST /api/login":               true,
+	"POST /api/refresh":             true,
+	"POST /api/revoke":              true,
+	"POST /api/users":               true,
+	"POST /api/users/verify":        true,
+	"POST /api/users/verify/resend": true,
+}
+
+// Middleware wrapper that counts API requests against a token bucket per
+// client and refuses them with 429 once it's empty. Signed-in clients are
+// limited by user ID and everyone else by IP address. Auth endpoints are
+// always limited by IP, or anyone guessing passwords with a token from each
+// of several accounts would get a bucket per account; so are webhooks, since
+// the sender isn't a user. Every limited response carries RateLimit-*
+// headers so clients can pace themselves.
+func (cfg *apiConfig) middlewareRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, policy := cfg.rateLimitPolicy(r)
+		if !policy.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := name + ":ip:" + ratelimit.ClientIP(r, cfg.config.TrustedProxies).String()
+		if name != rateLimitAuth && name != rateLimitWebhooks {
+			if userID, ok := cfg.requestUserID(r); ok {
+				key = name + ":user:" + userID.String()
+			}
+		}
+
+		result, err := cfg.rateLimiter.Take(r.Context(), key, policy, time.Now())
+		if err != nil {
+			// Better to let traffic through than to take the API down
+			// with the rate limit store.
+			slog.ErrorContext(r.Context(), "Couldn't check rate limit", "policy", name, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Period)))
+		if !result.Allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			cfg.metrics.RateLimited.WithLabelValues(name).Inc()
+			respondWithError(w, http.StatusTooManyRequests, "Too many requests", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitPolicy picks the policy for a request. Only the API is limited;
+// the file server, health checks and admin endpoints are not.
+func (cfg *apiConfig) rateLimitPolicy(r *http.Request) (string, ratelimit.Policy) {
+	switch {
+	case !strings.HasPrefix(r.URL.Path, "/api/"):
+		return "", ratelimit.Policy{}
+	case r.Method == http.MethodPost && r.URL.Path == "/api/polka/webhooks":
+		return rateLimitWebhooks, cfg.config.RateLimitWebhooks
+	case authRoutes[r.Method+" "+r.URL.Path]:
+		return rateLimitAuth, cfg.config.RateLimitAuth
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return rateLimitReads, cfg.config.RateLimitReads
+	default:
+		return rateLimitWrites, cfg.config.RateLimitWrites
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds, so a client that waits that long
+// is never early.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+)
+
+// Middleware wrapper that refuses writes from suspended users. Suspension
+// revokes refresh tokens and blocks logging in, but an access token already
+// issued stays valid until it expires, so every request that changes
+// something looks the caller up again, from the cache when there is one.
+// Reads go through, and requests without a valid access token are left to
+// the handler.
+func (cfg *apiConfig) middlewareSuspension(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		userID, err := auth.ValidateJWT(token, cfg.config.JWTSecret)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := cfg.databaseQueries.GetUserByID(r.Context(), userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't look up user", err)
+			return
+		}
+		if user.SuspendedAt.Valid {
+			respondWithError(w, http.StatusForbidden, "This account has been suspended", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/dandytron/chirpy.git/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware wrapper that serves each request inside a server span. An
+// inbound W3C traceparent header makes the span a child of the caller's
+// trace. Once the mux has matched a route the span is renamed after it.
+func middlewareTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		serveWithContext(next, rec, r, ctx)
+
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			route := r.Pattern
+			if _, path, ok := strings.Cut(route, " "); ok {
+				route = path
+			}
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status > 499 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// serveWithContext calls next with a copy of r carrying ctx, then copies the
+// route pattern the mux matched back onto r so outer middleware can see it.
+func serveWithContext(next http.Handler, w http.ResponseWriter, r *http.Request, ctx context.Context) {
+	req := r.WithContext(ctx)
+	next.ServeHTTP(w, req)
+	r.Pattern = req.Pattern
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// Notification types. Each one can be switched off per user through
+// the notification preferences endpoints. Replies, likes and follows will
+// get types of their own once Chirpy has them.
+const (
+	notificationTypeMention = "mention"
+	notificationTypeAccount = "account"
+)
+
+var notificationTypes = []string{
+	notificationTypeMention,
+	notificationTypeAccount,
+}
+
+func isNotificationType(t string) bool {
+	for _, known := range notificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// notify records a notification for userID. actorID and chirpID are optional
+// and may be uuid.Nil. Notifications are a side effect of whatever the caller
+// is doing, so failures are logged rather than returned.
+func (cfg *apiConfig) notify(ctx context.Context, userID, actorID uuid.UUID, notificationType string, chirpID uuid.UUID) {
+	err := cfg.databaseQueries.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  userID,
+		ActorID: uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		Type:    notificationType,
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: chirpID != uuid.Nil},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Couldn't create notification", "type", notificationType, "user_id", userID, "error", err)
+	}
+}
+
+// notifyMentions sends a mention notification to every user whose email is
+// referenced in the chirp body as "@user@example.com".
+func (cfg *apiConfig) notifyMentions(ctx context.Context, chirp database.Chirp) {
+	for _, email := range extractMentions(chirp.Body) {
+		user, err := cfg.databaseQueries.FindUserByEmail(ctx, email)
+		if err != nil {
+			continue
+		}
+		cfg.notify(ctx, user.ID, chirp.UserID, notificationTypeMention, chirp.ID)
+	}
+}
+
+// extractMentions returns the unique, lower-cased emails mentioned in a chirp.
+func extractMentions(body string) []string {
+	seen := map[string]struct{}{}
+	var mentions []string
+	for _, word := range strings.Fields(body) {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+		email := strings.ToLower(strings.TrimRight(word[1:], ".,!?:;)"))
+		if !strings.Contains(email, "@") {
+			continue
+		}
+		if _, ok := seen[email]; ok {
+			continue
+		}
+		seen[email] = struct{}{}
+		mentions = append(mentions, email)
+	}
+	return mentions
+}
+
+// notificationSummary builds the human-readable line for a notification group,
+// e.g. "5 people mentioned you in a chirp".
+func notificationSummary(notificationType string, actorCount int) string {
+	who := "Someone"
+	if actorCount > 1 {
+		who = fmt.Sprintf("%d people", actorCount)
+	}
+	switch notificationType {
+	case notificationTypeMention:
+		return who + " mentioned you in a chirp"
+	default:
+		return "There's an update on your account"
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parsePagination reads the optional "limit" and "offset" query parameters,
+// falling back to sensible defaults and capping the limit so a single request
+// can't pull an entire table.
+func parsePagination(r *http.Request) (limit, offset int32, err error) {
+	limit = defaultPageLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+		limit = int32(min(n, maxPageLimit))
+	}
+	if s := r.URL.Query().Get("offset"); s != "" {
+		// Parsing to 32 bits refuses offsets the database would overflow on.
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("offset must be an integer from 0 to %d", math.MaxInt32)
+		}
+		offset = int32(n)
+	}
+	return limit, offset, nil
+}
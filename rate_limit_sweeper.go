@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	rateLimitSweeperWorker = "rate_limit_sweeper"
+	rateLimitSweepInterval = time.Minute
+)
+
+// runRateLimitSweeper drops rate limit buckets that have refilled, so the
+// store only holds clients that have been busy recently.
+func (cfg *apiConfig) runRateLimitSweeper(ctx context.Context, interval time.Duration) {
+	cfg.workers.register(rateLimitSweeperWorker, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cfg.sweepRateLimits(ctx)
+	}
+}
+
+func (cfg *apiConfig) sweepRateLimits(ctx context.Context) {
+	deleted, err := cfg.rateLimiter.Sweep(ctx, time.Now())
+	cfg.workers.record(rateLimitSweeperWorker, err)
+	if err != nil {
+		slog.ErrorContext(ctx, "Couldn't sweep rate limits", "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.DebugContext(ctx, "Removed idle rate limit buckets", "count", deleted)
+	}
+}
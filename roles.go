@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/dandytron/chirpy.git/internal/database"
+	"github.com/google/uuid"
+)
+
+// userAccess loads the roles and permissions to embed in a user's access token.
+func (cfg *apiConfig) userAccess(ctx context.Context, userID uuid.UUID) (auth.Access, error) {
+	roles, err := cfg.databaseQueries.ListUserRoles(ctx, userID)
+	if err != nil {
+		return auth.Access{}, err
+	}
+	permissions, err := cfg.databaseQueries.ListUserPermissions(ctx, userID)
+	if err != nil {
+		return auth.Access{}, err
+	}
+	return auth.Access{
+		Roles:       roles,
+		Permissions: permissions,
+	}, nil
+}
+
+// bootstrapAdmin grants the admin role to the user whose verified email
+// matches ADMIN_EMAIL, so a fresh deployment has someone who can grant every
+// other role.
+func (cfg *apiConfig) bootstrapAdmin(ctx context.Context, user database.User) {
+	granted, err := cfg.service.BootstrapAdmin(ctx, user)
+	if err != nil {
+		slog.ErrorContext(ctx, "Couldn't grant admin role", "user_id", user.ID, "error", err)
+		return
+	}
+	if granted {
+		slog.InfoContext(ctx, "Granted admin role", "user_id", user.ID)
+	}
+}
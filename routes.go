@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// routes registers every endpoint and wraps them in the middleware shared by
+// all requests.
+func (cfg *apiConfig) routes() http.Handler {
+	mux := http.NewServeMux()
+	// Only the embedded web client is served, never files from disk.
+	mux.Handle("/app/", cfg.middlewareMetricsIncrementer(http.StripPrefix("/app", cfg.webClient)))
+	mux.HandleFunc("GET /admin/healthz", handlerLiveness)
+	mux.HandleFunc("GET /admin/livez", handlerLiveness)
+	mux.HandleFunc("GET /admin/readyz", cfg.handlerReadiness)
+	mux.Handle("GET /metrics", promhttp.HandlerFor(cfg.metrics.Registry, promhttp.HandlerOpts{}))
+
+	mux.HandleFunc("POST /api/login", cfg.loginHandler)
+	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
+	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
+	mux.HandleFunc("GET /api/csrf", cfg.csrfTokenHandler)
+
+	mux.HandleFunc("POST /api/users", cfg.createUserHandler)
+	mux.HandleFunc("PUT /api/users", cfg.updateUserHandler)
+	mux.HandleFunc("PATCH /api/users", cfg.updateUserHandler)
+	mux.HandleFunc("POST /api/users/verify", cfg.verifyEmailHandler)
+	mux.HandleFunc("POST /api/users/verify/resend", cfg.resendVerificationHandler)
+	mux.HandleFunc("DELETE /api/users/me", cfg.deleteAccountHandler)
+	mux.HandleFunc("GET /api/users/me/export", cfg.exportAccountHandler)
+	mux.HandleFunc("GET /api/users/me/blocks", cfg.listBlockedUsersHandler)
+	mux.HandleFunc("GET /api/users/me/mutes", cfg.listMutedUsersHandler)
+	mux.HandleFunc("POST /api/users/{userID}/block", cfg.blockUserHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", cfg.unblockUserHandler)
+	mux.HandleFunc("POST /api/users/{userID}/mute", cfg.muteUserHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", cfg.unmuteUserHandler)
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.chirpyRedHandler)
+
+	mux.HandleFunc("POST /api/chirps", cfg.createChirpHandler)
+	mux.HandleFunc("GET /api/chirps", cfg.retrieveAllChirpsHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.retrieveSingleChirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.deleteChirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", cfg.reportChirpHandler)
+	mux.HandleFunc("POST /api/users/{userID}/report", cfg.reportUserHandler)
+
+	mux.Handle("GET /api/moderation/reports", cfg.middlewareRequirePermission(auth.PermissionReportsReview, cfg.listReportsHandler))
+	mux.Handle("POST /api/moderation/reports/{reportID}/assign", cfg.middlewareRequirePermission(auth.PermissionReportsReview, cfg.assignReportHandler))
+	mux.Handle("POST /api/moderation/reports/{reportID}/actions", cfg.middlewareRequirePermission(auth.PermissionReportsReview, cfg.reportActionHandler))
+	mux.Handle("GET /api/moderation/actions", cfg.middlewareRequirePermission(auth.PermissionReportsReview, cfg.listModerationActionsHandler))
+
+	mux.HandleFunc("POST /api/conversations", cfg.createConversationHandler)
+	mux.HandleFunc("GET /api/conversations", cfg.listConversationsHandler)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", cfg.listMessagesHandler)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", cfg.createMessageHandler)
+	mux.HandleFunc("DELETE /api/conversations/{conversationID}/messages/{messageID}", cfg.deleteMessageHandler)
+
+	mux.HandleFunc("GET /api/notifications", cfg.listNotificationsHandler)
+	mux.HandleFunc("POST /api/notifications/read", cfg.markNotificationsReadHandler)
+	mux.HandleFunc("POST /api/notifications/read-all", cfg.markAllNotificationsReadHandler)
+	mux.HandleFunc("GET /api/notifications/preferences", cfg.getNotificationPreferencesHandler)
+	mux.HandleFunc("PUT /api/notifications/preferences", cfg.updateNotificationPreferencesHandler)
+
+	mux.Handle("GET /admin/metrics", cfg.middlewareRequirePermission(auth.PermissionMetricsRead, cfg.adminMetricsHandler))
+	mux.Handle("POST /admin/reset", cfg.middlewareRequirePermission(auth.PermissionSystemReset, cfg.resetHandler))
+
+	mux.Handle("GET /admin/roles", cfg.middlewareRequirePermission(auth.PermissionRolesManage, cfg.listRolesHandler))
+	mux.Handle("GET /admin/users/{userID}/roles", cfg.middlewareRequirePermission(auth.PermissionRolesManage, cfg.listUserRolesHandler))
+	mux.Handle("POST /admin/users/{userID}/roles", cfg.middlewareRequirePermission(auth.PermissionRolesManage, cfg.grantRoleHandler))
+	mux.Handle("DELETE /admin/users/{userID}/roles/{role}", cfg.middlewareRequirePermission(auth.PermissionRolesManage, cfg.revokeRoleHandler))
+
+	// Each wrapper runs before the ones above it.
+	handler := cfg.middlewareIdempotency(mux)
+	handler = cfg.middlewareSuspension(handler)
+	handler = cfg.middlewareCSRF(handler)
+	handler = cfg.middlewareRateLimit(handler)
+	handler = cfg.middlewareCORS(handler)
+	handler = cfg.middlewareSecurityHeaders(handler)
+	handler = cfg.middlewareMetrics(handler)
+	handler = cfg.middlewareLogging(handler)
+	return middlewareTracing(handler)
+}
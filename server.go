@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 15 * time.Second
+	// Long enough for an account export to be built and sent.
+	writeTimeout   = 60 * time.Second
+	idleTimeout    = 120 * time.Second
+	maxHeaderBytes = 1 << 20
+
+	shutdownTimeout = 30 * time.Second
+)
+
+func newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+		// net/http still negotiates HTTP/2 over TLS with a custom config.
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+		ErrorLog:  slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// errForcedShutdown is returned by runServer when it's told to stop before
+// the graceful shutdown has finished.
+var errForcedShutdown = errors.New("shutdown interrupted; in-flight requests were dropped")
+
+// runServer serves until ctx is cancelled and then shuts down gracefully:
+// readiness checks start failing, and after drainDelay, long enough for load
+// balancers to stop sending traffic, in-flight requests get up to
+// shutdownTimeout to finish. Cancelling force cuts that short, closing every
+// connection and returning errForcedShutdown. TLS is used when certFile and
+// keyFile are set.
+func (cfg *apiConfig) runServer(ctx, force context.Context, srv *http.Server, certFile, keyFile string, drainDelay time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		if certFile != "" {
+			serveErr <- srv.ListenAndServeTLS(certFile, keyFile)
+			return
+		}
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down; failing readiness checks", "drain_delay", drainDelay)
+	cfg.shuttingDown.Store(true)
+	drain := time.NewTimer(drainDelay)
+	defer drain.Stop()
+	select {
+	case <-drain.C:
+	case <-force.Done():
+		srv.Close()
+		return errForcedShutdown
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(force, shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if force.Err() != nil {
+		srv.Close()
+		return errForcedShutdown
+	}
+	if err != nil {
+		return err
+	}
+	err = <-serveErr
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// notifyShutdown returns a context cancelled by the first SIGINT or SIGTERM,
+// which starts a graceful shutdown, and one cancelled by the second, for
+// when whoever is stopping the server doesn't want to wait. stop stops
+// listening for signals.
+func notifyShutdown() (graceful, force context.Context, stop func()) {
+	graceful, stopGraceful := context.WithCancel(context.Background())
+	force, stopForce := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		for _, cancel := range []context.CancelFunc{stopGraceful, stopForce} {
+			select {
+			case <-signals:
+				cancel()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return graceful, force, func() {
+		signal.Stop(signals)
+		close(done)
+		stopGraceful()
+		stopForce()
+	}
+}
+
+// waitTimeout waits for wg and reports whether it finished within timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Every request gets its own connection, so the client never leaves a spare
+// one open that Shutdown would wait on.
+var serverTestClient = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+// startServer runs ts's routes through runServer on a free local port and
+// returns the base URL and the channel runServer's result arrives on.
+func startServer(t *testing.T, ts *testServer, ctx, force context.Context, drainDelay time.Duration) (string, <-chan error) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	srv := newServer(addr, ts.cfg.routes())
+	done := make(chan error, 1)
+	go func() {
+		done <- ts.cfg.runServer(ctx, force, srv, "", "", drainDelay)
+	}()
+	t.Cleanup(func() { srv.Close() })
+
+	url := "http://" + addr
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := serverTestClient.Get(url + "/admin/healthz")
+		if err == nil {
+			resp.Body.Close()
+			return url, done
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server didn't start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readyzStatus(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := serverTestClient.Get(url + "/admin/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestRunServerDrains(t *testing.T) {
+	ts := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startServer(t, ts, ctx, context.Background(), 200*time.Millisecond)
+
+	if got := readyzStatus(t, url); got != http.StatusOK {
+		t.Fatalf("readyz before shutdown = %d, want 200", got)
+	}
+	cancel()
+	// Requests are still served during the drain delay, so load balancers
+	// can see the server going out of rotation.
+	deadline := time.Now().Add(time.Second)
+	for readyzStatus(t, url) != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("readyz never started failing")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("runServer = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runServer didn't return after the drain delay")
+	}
+	if _, err := serverTestClient.Get(url + "/admin/healthz"); err == nil {
+		t.Error("server still accepting connections after shutdown")
+	}
+}
+
+func TestRunServerForcedStop(t *testing.T) {
+	ts := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	force, forceStop := context.WithCancel(context.Background())
+	defer forceStop()
+	url, done := startServer(t, ts, ctx, force, time.Hour)
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for readyzStatus(t, url) != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("readyz never started failing")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	forceStop()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errForcedShutdown) {
+			t.Fatalf("runServer = %v, want errForcedShutdown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("runServer didn't return right away when forced")
+	}
+}
+
+func TestNotifyShutdown(t *testing.T) {
+	graceful, force, stop := notifyShutdown()
+	defer stop()
+
+	signalSelf := func() {
+		t.Helper()
+		if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitDone := func(ctx context.Context, name string) {
+		t.Helper()
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s context not cancelled", name)
+		}
+	}
+
+	signalSelf()
+	waitDone(graceful, "graceful")
+	if force.Err() != nil {
+		t.Fatal("first signal cancelled the force context")
+	}
+	signalSelf()
+	waitDone(force, "force")
+}
+
+func TestWaitTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	if !waitTimeout(&wg, time.Second) {
+		t.Error("waitTimeout = false for a finished WaitGroup")
+	}
+
+	wg.Add(1)
+	if waitTimeout(&wg, 10*time.Millisecond) {
+		t.Error("waitTimeout = true for a WaitGroup still running")
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		wg.Done()
+	}()
+	if !waitTimeout(&wg, 5*time.Second) {
+		t.Error("waitTimeout = false for a WaitGroup that finished in time")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dandytron/chirpy.git/internal/auth"
+)
+
+// Ways a browser client can ask login and refresh to hand out tokens, with
+// the cookies query parameter. Tokens put in cookies are left out of the
+// JSON response, so page scripts never see them.
+const (
+	// cookiesNone returns both tokens in the response body.
+	cookiesNone = ""
+	// cookiesRefresh puts the refresh token in a cookie and returns the
+	// short-lived access token in the body.
+	cookiesRefresh = "refresh"
+	// cookiesAll puts both tokens in cookies.
+	cookiesAll = "all"
+)
+
+func cookieMode(r *http.Request) (string, error) {
+	mode := r.URL.Query().Get("cookies")
+	switch mode {
+	case cookiesNone, cookiesRefresh, cookiesAll:
+		return mode, nil
+	}
+	return "", fmt.Errorf("cookies must be %q or %q", cookiesRefresh, cookiesAll)
+}
+
+// setTokenCookie stores a token in an HttpOnly cookie that lasts as long as
+// the token does.
+func (cfg *apiConfig) setTokenCookie(w http.ResponseWriter, name, token string, ttl time.Duration) {
+	http.SetCookie(w, cfg.tokenCookie(name, token, int(ttl.Seconds())))
+}
+
+// clearTokenCookies tells the browser to forget both token cookies.
+func (cfg *apiConfig) clearTokenCookies(w http.ResponseWriter) {
+	http.SetCookie(w, cfg.tokenCookie(auth.AccessTokenCookie, "", -1))
+	http.SetCookie(w, cfg.tokenCookie(auth.RefreshTokenCookie, "", -1))
+}
+
+func (cfg *apiConfig) tokenCookie(name, value string, maxAge int) *http.Cookie {
+	sameSite := http.SameSiteLaxMode
+	switch cfg.config.CookieSameSite {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   cfg.config.CookieDomain,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   cfg.config.CookieSecure,
+		SameSite: sameSite,
+	}
+}
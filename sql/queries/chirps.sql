@@ -15,8 +15,48 @@ WHERE id = $1;
 
 -- name: RetrieveAllChirps :many
 SELECT * FROM chirps
+WHERE hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.deleted_at IS NOT NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = sqlc.arg(viewer_id))
+    OR (user_blocks.blocker_id = sqlc.arg(viewer_id) AND user_blocks.blocked_id = chirps.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = sqlc.arg(viewer_id)
+    AND user_mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at ASC;
 
 -- name: RetrieveSingleChirp :one
 SELECT * FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: RetrieveVisibleChirp :one
+SELECT * FROM chirps
+WHERE id = sqlc.arg(id)
+AND hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.deleted_at IS NOT NULL
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+    AND user_blocks.blocked_id = sqlc.arg(viewer_id)
+);
+
+-- name: HideChirp :exec
+UPDATE chirps SET hidden_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListChirpsByAuthor :many
+SELECT * FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,21 @@
+-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens (token, user_id, email, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: InvalidateEmailVerificationTokens :exec
+UPDATE email_verification_tokens SET used_at = NOW()
+WHERE user_id = $1
+AND used_at IS NULL;
+
+-- name: UseEmailVerificationToken :one
+UPDATE email_verification_tokens SET used_at = NOW()
+WHERE token = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: MarkEmailVerified :execrows
+UPDATE users SET email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1
+AND email = $2;
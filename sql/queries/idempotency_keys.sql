@@ -0,0 +1,30 @@
+-- name: ClaimIdempotencyKey :execrows
+INSERT INTO idempotency_keys (scope, key, fingerprint, locked_at, expires_at, lock_token)
+VALUES (sqlc.arg(scope), sqlc.arg(key), sqlc.arg(fingerprint), sqlc.arg(locked_at), sqlc.arg(expires_at), sqlc.arg(lock_token))
+ON CONFLICT (scope, key) DO UPDATE SET
+    fingerprint = EXCLUDED.fingerprint,
+    locked_at = EXCLUDED.locked_at,
+    lock_token = EXCLUDED.lock_token,
+    expires_at = EXCLUDED.expires_at,
+    response_status = 0,
+    response_content_type = '',
+    response_body = ''
+WHERE idempotency_keys.expires_at <= EXCLUDED.locked_at
+OR (idempotency_keys.response_status = 0 AND idempotency_keys.locked_at < sqlc.arg(stale_before));
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE scope = $1 AND key = $2;
+
+-- name: CompleteIdempotencyKey :execrows
+UPDATE idempotency_keys
+SET response_status = $4, response_content_type = $5, response_body = $6
+WHERE scope = $1 AND key = $2 AND lock_token = $3;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE scope = $1 AND key = $2 AND lock_token = $3;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE expires_at <= $1;
@@ -0,0 +1,118 @@
+-- name: CreateConversation :one
+WITH new_conversation AS (
+    INSERT INTO conversations (id, created_at, updated_at)
+    VALUES (gen_random_uuid(), NOW(), NOW())
+    RETURNING *
+), new_members AS (
+    INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+    SELECT new_conversation.id, member_id, NOW()
+    FROM new_conversation, UNNEST(sqlc.arg(member_ids)::uuid[]) AS member_id
+)
+SELECT * FROM new_conversation;
+
+-- name: FindDirectConversation :one
+SELECT conversations.* FROM conversations
+JOIN conversation_members AS a ON a.conversation_id = conversations.id
+JOIN conversation_members AS b ON b.conversation_id = conversations.id
+WHERE a.user_id = sqlc.arg(user_id)
+AND b.user_id = sqlc.arg(other_user_id)
+AND (
+    SELECT COUNT(*) FROM conversation_members
+    WHERE conversation_members.conversation_id = conversations.id
+) = 2
+ORDER BY conversations.created_at ASC
+LIMIT 1;
+
+-- name: IsConversationMember :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members
+    WHERE conversation_id = $1
+    AND user_id = $2
+);
+
+-- name: ListConversations :many
+WITH visible_messages AS (
+    SELECT messages.id, messages.created_at, messages.conversation_id, messages.sender_id, messages.body
+    FROM messages
+    JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id
+    WHERE conversation_members.user_id = $1
+    AND NOT EXISTS (
+        SELECT 1 FROM message_deletions
+        WHERE message_deletions.message_id = messages.id
+        AND message_deletions.user_id = $1
+    )
+), last_messages AS (
+    SELECT DISTINCT ON (visible_messages.conversation_id) visible_messages.*
+    FROM visible_messages
+    ORDER BY visible_messages.conversation_id, visible_messages.created_at DESC
+), unread_counts AS (
+    SELECT visible_messages.conversation_id, COUNT(*) AS unread_count
+    FROM visible_messages
+    JOIN conversation_members ON conversation_members.conversation_id = visible_messages.conversation_id
+    WHERE conversation_members.user_id = $1
+    AND visible_messages.sender_id <> $1
+    AND (conversation_members.last_read_at IS NULL OR visible_messages.created_at > conversation_members.last_read_at)
+    GROUP BY visible_messages.conversation_id
+)
+SELECT
+    conversations.id,
+    conversations.created_at,
+    conversations.updated_at,
+    ARRAY(
+        SELECT members.user_id FROM conversation_members AS members
+        WHERE members.conversation_id = conversations.id
+        ORDER BY members.joined_at, members.user_id
+    )::uuid[] AS member_ids,
+    last_messages.id AS last_message_id,
+    last_messages.sender_id AS last_message_sender_id,
+    last_messages.body AS last_message_body,
+    last_messages.created_at AS last_message_created_at,
+    COALESCE(unread_counts.unread_count, 0)::bigint AS unread_count
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+LEFT JOIN last_messages ON last_messages.conversation_id = conversations.id
+LEFT JOIN unread_counts ON unread_counts.conversation_id = conversations.id
+WHERE conversation_members.user_id = $1
+ORDER BY conversations.updated_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members SET last_read_at = NOW()
+WHERE conversation_id = $1
+AND user_id = $2;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListMessages :many
+SELECT messages.* FROM messages
+WHERE messages.conversation_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM message_deletions
+    WHERE message_deletions.message_id = messages.id
+    AND message_deletions.user_id = $2
+)
+ORDER BY messages.created_at DESC
+LIMIT $3 OFFSET $4;
+
+-- name: DeleteMessageForUser :execrows
+INSERT INTO message_deletions (message_id, user_id, deleted_at)
+SELECT messages.id, conversation_members.user_id, NOW()
+FROM messages
+JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id
+WHERE messages.id = $1
+AND messages.conversation_id = $2
+AND conversation_members.user_id = $3
+ON CONFLICT DO NOTHING;
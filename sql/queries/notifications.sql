@@ -0,0 +1,77 @@
+-- name: CreateNotification :exec
+INSERT INTO notifications (id, created_at, user_id, actor_id, type, chirp_id)
+SELECT gen_random_uuid(), NOW(), sqlc.arg(user_id)::uuid, sqlc.narg(actor_id)::uuid, sqlc.arg(type)::text, sqlc.narg(chirp_id)::uuid
+WHERE sqlc.narg(actor_id)::uuid IS DISTINCT FROM sqlc.arg(user_id)::uuid
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = sqlc.arg(user_id)::uuid
+    AND user_blocks.blocked_id = sqlc.narg(actor_id)::uuid
+)
+AND NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE notification_preferences.user_id = sqlc.arg(user_id)::uuid
+    AND notification_preferences.type = sqlc.arg(type)::text
+    AND notification_preferences.enabled = false
+);
+
+-- name: ListNotificationGroups :many
+SELECT
+    type,
+    chirp_id,
+    ARRAY_AGG(id ORDER BY created_at DESC)::uuid[] AS ids,
+    (ARRAY_AGG(DISTINCT actor_id) FILTER (WHERE actor_id IS NOT NULL))::uuid[] AS actor_ids,
+    COUNT(*) FILTER (WHERE read_at IS NULL) AS unread_count,
+    MAX(created_at)::timestamp AS latest_at
+FROM notifications
+WHERE user_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = notifications.user_id
+    AND user_mutes.muted_id = notifications.actor_id
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = notifications.user_id
+    AND user_blocks.blocked_id = notifications.actor_id
+)
+GROUP BY type, chirp_id
+ORDER BY latest_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1
+AND read_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = notifications.user_id
+    AND user_mutes.muted_id = notifications.actor_id
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = notifications.user_id
+    AND user_blocks.blocked_id = notifications.actor_id
+);
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id)
+AND id = ANY(sqlc.arg(ids)::uuid[])
+AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1
+ORDER BY type;
+
+-- name: UpsertNotificationPreference :one
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled, updated_at = NOW()
+RETURNING *;
@@ -0,0 +1,17 @@
+-- name: CreateRateLimit :exec
+INSERT INTO rate_limits (key, full_at)
+VALUES ($1, $2)
+ON CONFLICT (key) DO NOTHING;
+
+-- name: GetRateLimitForUpdate :one
+SELECT full_at FROM rate_limits
+WHERE key = $1
+FOR UPDATE;
+
+-- name: UpdateRateLimit :exec
+UPDATE rate_limits SET full_at = $2
+WHERE key = $1;
+
+-- name: DeleteExpiredRateLimits :execrows
+DELETE FROM rate_limits
+WHERE full_at <= $1;
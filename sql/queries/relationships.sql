@@ -0,0 +1,49 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1
+AND blocked_id = $2;
+
+-- name: ListBlockedUsers :many
+SELECT * FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: HasBlockBetween :one
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(other_ids)::uuid[]))
+    OR (blocker_id = ANY(sqlc.arg(other_ids)::uuid[]) AND blocked_id = sqlc.arg(user_id))
+);
+
+-- name: HasBlockInConversation :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members
+    JOIN user_blocks ON (
+        user_blocks.blocker_id = conversation_members.user_id
+        AND user_blocks.blocked_id = sqlc.arg(user_id)
+    ) OR (
+        user_blocks.blocker_id = sqlc.arg(user_id)
+        AND user_blocks.blocked_id = conversation_members.user_id
+    )
+    WHERE conversation_members.conversation_id = sqlc.arg(conversation_id)
+);
+
+-- name: MuteUser :exec
+INSERT INTO user_mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM user_mutes
+WHERE muter_id = $1
+AND muted_id = $2;
+
+-- name: ListMutedUsers :many
+SELECT * FROM user_mutes
+WHERE muter_id = $1
+ORDER BY created_at DESC;
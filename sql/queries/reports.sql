@@ -0,0 +1,56 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: ListReports :many
+SELECT * FROM reports
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+AND (sqlc.narg(reason)::text IS NULL OR reason = sqlc.narg(reason)::text)
+AND (sqlc.narg(assignee_id)::uuid IS NULL OR assignee_id = sqlc.narg(assignee_id)::uuid)
+AND (NOT sqlc.arg(unassigned)::boolean OR assignee_id IS NULL)
+ORDER BY created_at ASC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: AssignReport :one
+UPDATE reports SET assignee_id = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CloseReport :one
+UPDATE reports SET status = $2, resolved_at = NOW(), updated_at = NOW()
+WHERE id = $1
+AND status = 'open'
+RETURNING *;
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, report_id, action, target_user_id, target_chirp_id, note)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: ListModerationActions :many
+SELECT * FROM moderation_actions
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
@@ -0,0 +1,44 @@
+-- name: ListRoles :many
+SELECT
+    roles.name,
+    roles.description,
+    ARRAY(
+        SELECT role_permissions.permission FROM role_permissions
+        WHERE role_permissions.role = roles.name
+        ORDER BY role_permissions.permission
+    )::text[] AS permissions
+FROM roles
+ORDER BY roles.name;
+
+-- name: GetRole :one
+SELECT * FROM roles
+WHERE name = $1;
+
+-- name: ListUserRoles :many
+SELECT role FROM user_roles
+WHERE user_id = $1
+ORDER BY role;
+
+-- name: ListUserPermissions :many
+SELECT DISTINCT role_permissions.permission FROM role_permissions
+JOIN user_roles ON user_roles.role = role_permissions.role
+WHERE user_roles.user_id = $1
+ORDER BY role_permissions.permission;
+
+-- name: UserHasPermission :one
+SELECT EXISTS (
+    SELECT 1 FROM user_roles
+    JOIN role_permissions ON role_permissions.role = user_roles.role
+    WHERE user_roles.user_id = $1
+    AND role_permissions.permission = $2
+);
+
+-- name: GrantRole :execrows
+INSERT INTO user_roles (user_id, role, granted_at, granted_by)
+VALUES ($1, $2, NOW(), $3)
+ON CONFLICT DO NOTHING;
+
+-- name: RevokeRole :execrows
+DELETE FROM user_roles
+WHERE user_id = $1
+AND role = $2;
@@ -12,11 +12,54 @@ DELETE FROM users;
 SELECT * FROM users
 WHERE email = $1;
 
--- name: UpdateUser :one
-UPDATE users SET email = $2, hashed_password = $3, updated_at = NOW()
+-- name: ListRecentUsers :many
+SELECT * FROM users
+WHERE created_at >= sqlc.arg(since)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: UpdateUserEmail :one
+UPDATE users SET email = $2, email_verified_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users SET hashed_password = $2, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
 -- name: UpgradeToChirpyRed :exec
 UPDATE users SET is_chirpy_red = true
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: CountUsersByIDs :one
+SELECT COUNT(*) FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+AND deleted_at IS NULL;
+
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: GetUserPasswordHash :one
+SELECT hashed_password FROM users
+WHERE id = $1;
+
+-- name: SuspendUser :exec
+UPDATE users SET suspended_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: SoftDeleteUser :exec
+UPDATE users SET deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RestoreUser :one
+UPDATE users SET deleted_at = NULL, updated_at = NOW()
+WHERE id = sqlc.arg(id)
+AND deleted_at > NOW() - (sqlc.arg(grace_days)::int * INTERVAL '1 day')
+RETURNING *;
+
+-- name: HardDeleteExpiredUsers :execrows
+DELETE FROM users
+WHERE deleted_at < NOW() - (sqlc.arg(grace_days)::int * INTERVAL '1 day');
@@ -0,0 +1,40 @@
+-- +goose Up
+ALTER TABLE users ADD is_moderator BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD suspended_at TIMESTAMP;
+ALTER TABLE chirps ADD hidden_at TIMESTAMP;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reported_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'misinformation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'dismissed')),
+    assignee_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX reports_status_created_at_idx ON reports (status, created_at);
+
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    report_id UUID REFERENCES reports(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    target_user_id UUID,
+    target_chirp_id UUID,
+    note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX moderation_actions_created_at_idx ON moderation_actions (created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS moderation_actions;
+DROP TABLE IF EXISTS reports;
+ALTER TABLE chirps DROP COLUMN IF EXISTS hidden_at;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_at;
+ALTER TABLE users DROP COLUMN IF EXISTS is_moderator;
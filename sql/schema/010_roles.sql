@@ -0,0 +1,48 @@
+-- +goose Up
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE role_permissions (
+    role TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    granted_at TIMESTAMP NOT NULL,
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    PRIMARY KEY (user_id, role)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access, including metrics, resets and role management'),
+    ('moderator', 'Reviews reports and can remove anyone''s chirps');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'metrics:read'),
+    ('admin', 'system:reset'),
+    ('admin', 'roles:manage'),
+    ('admin', 'reports:review'),
+    ('admin', 'chirps:delete_any'),
+    ('moderator', 'reports:review'),
+    ('moderator', 'chirps:delete_any');
+
+INSERT INTO user_roles (user_id, role, granted_at)
+SELECT id, 'moderator', NOW() FROM users
+WHERE is_moderator;
+
+ALTER TABLE users DROP COLUMN is_moderator;
+
+-- +goose Down
+ALTER TABLE users ADD is_moderator BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users SET is_moderator = true
+WHERE id IN (SELECT user_id FROM user_roles WHERE role = 'moderator');
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users ADD email_verified_at TIMESTAMP;
+
+CREATE TABLE email_verification_tokens (
+    token TEXT PRIMARY KEY,
+    user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX email_verification_tokens_user_id_idx ON email_verification_tokens (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS email_verification_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE rate_limits (
+    key TEXT PRIMARY KEY,
+    full_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX rate_limits_full_at_idx ON rate_limits (full_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS rate_limits;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE idempotency_keys (
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    locked_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    -- 0 while the first request with the key is still being handled.
+    response_status INTEGER NOT NULL DEFAULT 0,
+    response_content_type TEXT NOT NULL DEFAULT '',
+    response_body BYTEA NOT NULL DEFAULT '',
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_keys;
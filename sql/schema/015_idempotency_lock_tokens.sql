@@ -0,0 +1,9 @@
+-- +goose Up
+-- Identifies the request holding a key, so one whose key was taken over as
+-- abandoned can't overwrite or release it when it finally finishes. Keys
+-- claimed before this have the nil token.
+ALTER TABLE idempotency_keys ADD lock_token uuid NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000';
+ALTER TABLE idempotency_keys ALTER lock_token DROP DEFAULT;
+
+-- +goose Down
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS lock_token;
@@ -0,0 +1,33 @@
+// Package schema embeds the goose migrations in this directory so the server
+// can check, and later apply, them without the files on disk.
+package schema
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// LatestVersion returns the version of the newest migration, taken from the
+// numeric prefix goose reads from each file name.
+func LatestVersion() (int64, error) {
+	files, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest int64
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(file, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s has no version prefix", file)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
@@ -0,0 +1,245 @@
+// Chirpy web client. It signs in with cookie sessions, so no token is ever
+// visible to this script: requests that change something carry the
+// session's CSRF token instead.
+
+const base = "/app/";
+const userKey = "chirpy.user";
+
+let csrfToken = "";
+
+function currentUser() {
+    try {
+        return JSON.parse(localStorage.getItem(userKey));
+    } catch {
+        return null;
+    }
+}
+
+function setCurrentUser(user) {
+    if (user) {
+        localStorage.setItem(userKey, JSON.stringify({ id: user.id, email: user.email }));
+    } else {
+        localStorage.removeItem(userKey);
+    }
+}
+
+class APIError extends Error {
+    constructor(status, message) {
+        super(message);
+        this.status = status;
+    }
+}
+
+async function send(method, path, body) {
+    const headers = { Accept: "application/json" };
+    if (body !== undefined) {
+        headers["Content-Type"] = "application/json";
+    }
+    if (method !== "GET" && csrfToken) {
+        headers["X-CSRF-Token"] = csrfToken;
+    }
+    const resp = await fetch(path, {
+        method,
+        headers,
+        credentials: "same-origin",
+        body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    if (resp.status === 204) {
+        return null;
+    }
+    const data = await resp.json().catch(() => null);
+    if (!resp.ok) {
+        throw new APIError(resp.status, (data && data.error) || resp.statusText);
+    }
+    return data;
+}
+
+// api sends a request, first renewing the access cookie if it has expired.
+async function api(method, path, body) {
+    try {
+        return await send(method, path, body);
+    } catch (err) {
+        if (!(err instanceof APIError) || err.status !== 401 || !currentUser()) {
+            throw err;
+        }
+    }
+    if (!(await refreshSession())) {
+        throw new APIError(401, "Your session has ended; please log in again");
+    }
+    return send(method, path, body);
+}
+
+async function refreshSession() {
+    try {
+        if (!csrfToken) {
+            csrfToken = (await send("GET", "/api/csrf")).csrf_token;
+        }
+        await send("POST", "/api/refresh?cookies=all");
+        return true;
+    } catch {
+        csrfToken = "";
+        setCurrentUser(null);
+        renderSession();
+        return false;
+    }
+}
+
+async function login(email, password) {
+    const user = await send("POST", "/api/login?cookies=all", { email, password });
+    csrfToken = user.csrf_token;
+    setCurrentUser(user);
+}
+
+async function logout() {
+    try {
+        await api("POST", "/api/revoke");
+    } finally {
+        csrfToken = "";
+        setCurrentUser(null);
+    }
+}
+
+const $ = (id) => document.getElementById(id);
+
+function showError(err) {
+    const el = $("error");
+    el.textContent = err ? err.message : "";
+    el.hidden = !err;
+}
+
+function renderSession() {
+    const user = currentUser();
+    $("whoami").textContent = user ? user.email : "";
+    $("whoami").hidden = !user;
+    $("logout").hidden = !user;
+    $("login-link").hidden = !!user;
+    $("chirp-form").hidden = !user;
+}
+
+function renderChirp(chirp) {
+    const item = $("chirp-template").content.firstElementChild.cloneNode(true);
+    item.querySelector(".body").textContent = chirp.body;
+    const link = item.querySelector(".permalink");
+    link.href = base + "chirps/" + chirp.id;
+    link.textContent = new Date(chirp.created_at).toLocaleString();
+    const user = currentUser();
+    if (user && user.id === chirp.user_id) {
+        const del = item.querySelector(".delete");
+        del.hidden = false;
+        del.addEventListener("click", async () => {
+            try {
+                await api("DELETE", "/api/chirps/" + chirp.id);
+                item.remove();
+            } catch (err) {
+                showError(err);
+            }
+        });
+    }
+    return item;
+}
+
+function show(view) {
+    for (const id of ["login-view", "feed-view", "chirp-view"]) {
+        $(id).hidden = id !== view;
+    }
+}
+
+const routes = [
+    [/^login$/, () => show("login-view")],
+    [/^chirps\/([0-9a-f-]+)$/, async (id) => {
+        show("chirp-view");
+        $("chirp").replaceChildren(renderChirp(await api("GET", "/api/chirps/" + id)));
+    }],
+    [/^$/, async () => {
+        show("feed-view");
+        const chirps = (await api("GET", "/api/chirps")) || [];
+        chirps.sort((a, b) => b.created_at.localeCompare(a.created_at));
+        $("chirps").replaceChildren(...chirps.map(renderChirp));
+    }],
+];
+
+// route renders the view for the current URL. The server answers every
+// path under /app/ with this page, so routing happens here.
+async function route() {
+    showError(null);
+    renderSession();
+    const path = location.pathname.startsWith(base) ? location.pathname.slice(base.length) : "";
+    for (const [pattern, render] of routes) {
+        const match = path.replace(/\/$/, "").match(pattern);
+        if (match) {
+            try {
+                await render(...match.slice(1));
+            } catch (err) {
+                showError(err);
+            }
+            return;
+        }
+    }
+    navigate(base, true);
+}
+
+function navigate(url, replace) {
+    if (replace) {
+        history.replaceState(null, "", url);
+    } else {
+        history.pushState(null, "", url);
+    }
+    route();
+}
+
+document.addEventListener("click", (event) => {
+    const link = event.target.closest("a[data-link]");
+    if (link && !event.metaKey && !event.ctrlKey && !event.shiftKey) {
+        event.preventDefault();
+        navigate(link.getAttribute("href"));
+    }
+});
+
+window.addEventListener("popstate", route);
+
+$("login-form").addEventListener("submit", async (event) => {
+    event.preventDefault();
+    const form = event.target;
+    const email = form.elements.email.value;
+    const password = form.elements.password.value;
+    try {
+        if (event.submitter && event.submitter.value === "signup") {
+            await send("POST", "/api/users", { email, password });
+        }
+        await login(email, password);
+        form.reset();
+        navigate(base);
+    } catch (err) {
+        showError(err);
+    }
+});
+
+$("chirp-form").addEventListener("submit", async (event) => {
+    event.preventDefault();
+    const form = event.target;
+    try {
+        const chirp = await api("POST", "/api/chirps", { body: form.elements.body.value });
+        form.reset();
+        $("chirps").prepend(renderChirp(chirp));
+    } catch (err) {
+        showError(err);
+    }
+});
+
+$("logout").addEventListener("click", async () => {
+    try {
+        await logout();
+    } catch (err) {
+        showError(err);
+    }
+    navigate(base);
+});
+
+// A page loaded after the access cookie expired still has a session, but
+// needs the CSRF token back before it can change anything.
+if (currentUser()) {
+    send("GET", "/api/csrf")
+        .then((data) => { csrfToken = data.csrf_token; })
+        .catch(() => { setCurrentUser(null); renderSession(); });
+}
+route();
@@ -0,0 +1,15 @@
+// Package web embeds the Chirpy web client, so the server only ever serves
+// the files in this directory and needs none of them on disk.
+//
+// HTML pages are templates: link to other files with {{asset "name"}},
+// which the server replaces with a content-hashed URL. After changing any
+// other file, run go generate to compress it again; the server won't start
+// with a compressed copy that's out of date. See internal/static.
+package web
+
+import "embed"
+
+//go:generate go run precompress.go
+
+//go:embed index.html assets
+var FS embed.FS
@@ -0,0 +1,17 @@
+//go:build ignore
+
+// Precompress writes the .br and .gz copies of the web client's files that
+// the server serves to browsers accepting them. Run it with go generate.
+package main
+
+import (
+	"log"
+
+	"github.com/dandytron/chirpy.git/internal/static"
+)
+
+func main() {
+	if err := static.Precompress("."); err != nil {
+		log.Fatal(err)
+	}
+}